@@ -0,0 +1,328 @@
+package maze
+
+// This file contains utilities for producing animations of mazes, such as
+// showing the order in which walls were removed during generation.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+)
+
+// Options controlling how an animated GIF is produced.
+type GIFOptions struct {
+	// The number of steps shown in each frame of the animation. Values less
+	// than 1 are treated as 1. Increasing this reduces the size of the GIF.
+	StepsPerFrame int
+	// The delay between frames, in 100ths of a second.
+	Delay int
+	// The time to show the final frame before the animation loops, in 100ths
+	// of a second.
+	FinalDelay int
+}
+
+// Returns the default options used if nil GIFOptions are provided.
+func DefaultGIFOptions() *GIFOptions {
+	return &GIFOptions{
+		StepsPerFrame: 1,
+		Delay:         5,
+		FinalDelay:    300,
+	}
+}
+
+// The palette used for every frame of an animation.
+var animationPalette = color.Palette{
+	color.Black,
+	color.White,
+	color.Transparent,
+	solutionColor,
+	frontierColor,
+	visitedColor,
+	deadEndColor,
+}
+
+// The colors used when animating a solver's search.
+var (
+	solutionColor = color.RGBA{230, 20, 20, 255}
+	frontierColor = color.RGBA{120, 160, 255, 255}
+	visitedColor  = color.RGBA{150, 220, 150, 255}
+	deadEndColor  = color.RGBA{170, 170, 170, 255}
+)
+
+// Rasterizes the given image into a single animation frame.
+func palettedFrame(pic image.Image, palette color.Palette) *image.Paletted {
+	bounds := pic.Bounds()
+	toReturn := image.NewPaletted(bounds, palette)
+	draw.Draw(toReturn, bounds, pic, bounds.Min, draw.Src)
+	return toReturn
+}
+
+// Builds an animation from a sequence of steps. The step function is called
+// with each step index in order, and must update the state of pic for that
+// step. A frame of pic is captured before any steps are taken, after every
+// opts.StepsPerFrame steps, and after the final step.
+func buildAnimation(pic image.Image, palette color.Palette, stepCount int,
+	opts *GIFOptions, step func(i int) error) (*gif.GIF, error) {
+	if opts == nil {
+		opts = DefaultGIFOptions()
+	}
+	stepsPerFrame := opts.StepsPerFrame
+	if stepsPerFrame < 1 {
+		stepsPerFrame = 1
+	}
+	toReturn := &gif.GIF{}
+	addFrame := func(delay int) {
+		toReturn.Image = append(toReturn.Image, palettedFrame(pic, palette))
+		toReturn.Delay = append(toReturn.Delay, delay)
+	}
+	addFrame(opts.Delay)
+	for i := 0; i < stepCount; i++ {
+		e := step(i)
+		if e != nil {
+			return nil, e
+		}
+		if (i == (stepCount - 1)) || (((i + 1) % stepsPerFrame) != 0) {
+			continue
+		}
+		addFrame(opts.Delay)
+	}
+	if stepCount != 0 {
+		addFrame(opts.FinalDelay)
+	} else {
+		toReturn.Delay[0] = opts.FinalDelay
+	}
+	return toReturn, nil
+}
+
+// Returns a copy of the maze with the solution cleared, but which is otherwise
+// identical to the original. The copy's cells can't be used for generation.
+func (m *GridMaze) copyWithoutSolution() *GridMaze {
+	toReturn := *m
+	toReturn.cells = make([]gridMazeCell, len(m.cells))
+	toReturn.neighbors = nil
+	toReturn.observer = nil
+	copy(toReturn.cells, m.cells)
+	for i := range toReturn.cells {
+		c := &(toReturn.cells[i])
+		if c.state == 1 {
+			c.state = 0
+		}
+		c.parent = &toReturn
+		c.djSet = nil
+	}
+	return &toReturn
+}
+
+// Returns a copy of the maze with every wall set and the solution cleared, but
+// with the same size, excluded cells, and endpoints as the original. The
+// copy's cells can't be used for generation.
+func (m *GridMaze) blankCopy() *GridMaze {
+	toReturn := m.copyWithoutSolution()
+	for i := range toReturn.cells {
+		c := &(toReturn.cells[i])
+		for j := range c.walls {
+			c.walls[j] = true
+		}
+	}
+	return toReturn
+}
+
+// A single wall removal recorded by a GenerationRecorder.
+type wallRemoval struct {
+	cellIndex int
+	direction int
+}
+
+// Satisfies the GenerationObserver interface, and records the order in which
+// walls were removed in order to produce an animation of the maze's
+// generation. Use by passing a GenerationRecorder to SetGenerationObserver
+// before generating a maze.
+type GenerationRecorder struct {
+	removals []wallRemoval
+}
+
+func (r *GenerationRecorder) GenerationStarted(m *GridMaze) {
+	r.removals = r.removals[:0]
+}
+
+func (r *GenerationRecorder) WallRemoved(cellIndex, direction int) {
+	r.removals = append(r.removals, wallRemoval{
+		cellIndex: cellIndex,
+		direction: direction,
+	})
+}
+
+// Returns the number of wall removals recorded during the last generation.
+func (r *GenerationRecorder) StepCount() int {
+	return len(r.removals)
+}
+
+// Builds an animation showing the walls of m being removed in the order they
+// were recorded. The given maze must be the one that was generated while this
+// recorder was observing it. If opts is nil, DefaultGIFOptions() is used.
+func (r *GenerationRecorder) Animation(m *GridMaze,
+	opts *GIFOptions) (*gif.GIF, error) {
+	canvas := m.blankCopy()
+	return buildAnimation(canvas, animationPalette, len(r.removals), opts,
+		func(i int) error {
+			w := r.removals[i]
+			if (w.cellIndex < 0) || (w.cellIndex >= len(canvas.cells)) {
+				return fmt.Errorf("Recorded step %d refers to invalid cell "+
+					"%d; was the recorder used with a different maze?", i,
+					w.cellIndex)
+			}
+			canvas.setWall(w.cellIndex, w.direction, false)
+			return nil
+		})
+}
+
+// Writes an animated GIF showing the walls of m being removed in the order
+// they were recorded. See the comment on Animation.
+func (r *GenerationRecorder) WriteGIF(w io.Writer, m *GridMaze,
+	opts *GIFOptions) error {
+	animation, e := r.Animation(m, opts)
+	if e != nil {
+		return e
+	}
+	e = gif.EncodeAll(w, animation)
+	if e != nil {
+		return fmt.Errorf("Error encoding GIF: %w", e)
+	}
+	return nil
+}
+
+// Identifies the kind of step taken by a solver when searching a maze.
+type SearchEventKind uint8
+
+const (
+	// The solver added the cell to the set of cells it will explore later.
+	SearchFrontier SearchEventKind = iota
+	// The solver moved into the cell.
+	SearchVisited
+	// The solver found no unvisited cells reachable from the cell, and will
+	// need to backtrack.
+	SearchDeadEnd
+)
+
+func (k SearchEventKind) String() string {
+	switch k {
+	case SearchFrontier:
+		return "frontier"
+	case SearchVisited:
+		return "visited"
+	case SearchDeadEnd:
+		return "deadEnd"
+	}
+	return fmt.Sprintf("Unknown SearchEventKind: %d", uint8(k))
+}
+
+// A single step taken by a solver.
+type SearchEvent struct {
+	// The column and row of the cell the event applies to.
+	Cell image.Point
+	Kind SearchEventKind
+}
+
+// Records every step taken by a solver while it searches for a solution.
+// Obtain one using GridMaze.TraceSolution.
+type SolverTrace struct {
+	// The steps taken by the solver, in order.
+	Events []SearchEvent
+	// The solution the solver found, from the start to the end.
+	Path []image.Point
+}
+
+// Appends an event to the trace. Does nothing if t is nil, so that solvers
+// can record events unconditionally.
+func (t *SolverTrace) record(m *GridMaze, cellIndex int,
+	kind SearchEventKind) {
+	if t == nil {
+		return
+	}
+	t.Events = append(t.Events, SearchEvent{
+		Cell: m.cellPoint(cellIndex),
+		Kind: kind,
+	})
+}
+
+// Sets the trace's path to the given list of cell indices. Does nothing if t
+// is nil.
+func (t *SolverTrace) setPath(m *GridMaze, path []int) {
+	if t == nil {
+		return
+	}
+	t.Path = make([]image.Point, len(path))
+	for i, index := range path {
+		t.Path[i] = m.cellPoint(index)
+	}
+}
+
+// Solves the maze in the same way as ShowSolution, but returns a record of
+// every step the solver took rather than highlighting the solution.
+func (m *GridMaze) TraceSolution() (*SolverTrace, error) {
+	toReturn := &SolverTrace{}
+	path, e := m.solveRoute(toReturn)
+	if e != nil {
+		return nil, e
+	}
+	toReturn.setPath(m, path)
+	return toReturn, nil
+}
+
+// Builds an animation of the solver exploring the maze, one event at a time.
+// Cells in the frontier are blue, visited cells are green, and dead ends are
+// gray. The final frame shows the path that was found in red. The given maze
+// must be the one that was traced. If opts is nil, DefaultGIFOptions() is
+// used.
+func (t *SolverTrace) Animation(m *GridMaze,
+	opts *GIFOptions) (*gif.GIF, error) {
+	overlay := newCellOverlay(m.copyWithoutSolution())
+	return buildAnimation(overlay, animationPalette, len(t.Events)+1, opts,
+		func(i int) error {
+			// The final step shows the entire path at once.
+			if i == len(t.Events) {
+				for _, p := range t.Path {
+					index, e := m.cellIndex(p)
+					if e != nil {
+						return e
+					}
+					overlay.colors[index] = solutionColor
+				}
+				return nil
+			}
+			event := t.Events[i]
+			index, e := m.cellIndex(event.Cell)
+			if e != nil {
+				return e
+			}
+			switch event.Kind {
+			case SearchFrontier:
+				overlay.colors[index] = frontierColor
+			case SearchVisited:
+				overlay.colors[index] = visitedColor
+			case SearchDeadEnd:
+				overlay.colors[index] = deadEndColor
+			default:
+				return fmt.Errorf("Invalid search event kind: %s", event.Kind)
+			}
+			return nil
+		})
+}
+
+// Writes an animated GIF showing the solver's search. See the comment on
+// Animation.
+func (t *SolverTrace) WriteGIF(w io.Writer, m *GridMaze,
+	opts *GIFOptions) error {
+	animation, e := t.Animation(m, opts)
+	if e != nil {
+		return e
+	}
+	e = gif.EncodeAll(w, animation)
+	if e != nil {
+		return fmt.Errorf("Error encoding GIF: %w", e)
+	}
+	return nil
+}
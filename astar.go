@@ -0,0 +1,179 @@
+package maze
+
+// This file contains an A* solver for GridMazes, which records statistics
+// about its search for use in comparing pathfinding implementations.
+
+import (
+	"container/heap"
+	"fmt"
+	"image"
+	"math"
+	"time"
+)
+
+// Identifies a heuristic used by SolveAStar to estimate the remaining cost
+// from a cell to the goal.
+type Heuristic uint8
+
+const (
+	// Estimates every remaining cost as 0, so A* behaves like Dijkstra's
+	// algorithm.
+	HeuristicZero Heuristic = iota
+	// Uses the number of rows plus the number of columns to the goal.
+	HeuristicManhattan
+	// Uses the straight-line distance to the goal, in cells.
+	HeuristicEuclidean
+)
+
+func (h Heuristic) String() string {
+	switch h {
+	case HeuristicZero:
+		return "zero"
+	case HeuristicManhattan:
+		return "manhattan"
+	case HeuristicEuclidean:
+		return "euclidean"
+	}
+	return fmt.Sprintf("Unknown Heuristic: %d", uint8(h))
+}
+
+// Returns the heuristic's estimate of the number of moves between the two
+// cells. None of the heuristics overestimate the number of moves in a maze
+// without portals.
+func (h Heuristic) Estimate(from, to image.Point) float64 {
+	dx := float64(from.X - to.X)
+	dy := float64(from.Y - to.Y)
+	switch h {
+	case HeuristicManhattan:
+		return math.Abs(dx) + math.Abs(dy)
+	case HeuristicEuclidean:
+		return math.Sqrt(dx*dx + dy*dy)
+	}
+	return 0
+}
+
+// The result of SolveAStar, including statistics about the search.
+type AStarResult struct {
+	// The cells along the cheapest solution, from the start to the end.
+	Path []image.Point
+	// The total cost of the path, as used by CheapestSolution.
+	Cost int
+	// The number of cells removed from the open set and expanded. If the
+	// maze has waypoints, this is the total across every leg of the route.
+	Expanded int
+	// The largest number of cells in the open set at any one time.
+	PeakFrontier int
+	// The time taken by the search.
+	Elapsed time.Duration
+}
+
+// Returns the lowest possible cost of any single move in the maze. Used to
+// scale heuristics so they never overestimate the remaining cost.
+func (m *GridMaze) minimumMoveCost() int {
+	toReturn := m.TerrainCost(TerrainNormal)
+	for t := TerrainNormal; t <= TerrainStairs; t++ {
+		cost := m.TerrainCost(t)
+		if cost < toReturn {
+			toReturn = cost
+		}
+	}
+	for _, cost := range m.passageCosts {
+		if cost < toReturn {
+			toReturn = cost
+		}
+	}
+	return toReturn
+}
+
+// Runs A* from the cell at startIndex to the cell at endIndex, adding to the
+// statistics in result. Returns the path's cell indices and its cost.
+func (m *GridMaze) aStarPath(startIndex, endIndex int, h Heuristic,
+	result *AStarResult) ([]int, int, error) {
+	scale := float64(m.minimumMoveCost())
+	goal := m.cellPoint(endIndex)
+	costs := make([]int, len(m.cells))
+	parents := make([]int, len(m.cells))
+	done := make([]bool, len(m.cells))
+	for i := range costs {
+		costs[i] = -1
+		parents[i] = -1
+	}
+	costs[startIndex] = 0
+	q := &priorityQueue{{startIndex,
+		h.Estimate(m.cellPoint(startIndex), goal) * scale}}
+	// Cells may be in the heap more than once if a cheaper path to them is
+	// found, so track the actual size of the open set separately.
+	openCount := 1
+	if result.PeakFrontier < openCount {
+		result.PeakFrontier = openCount
+	}
+	var neighbors []int
+	for q.Len() != 0 {
+		current := heap.Pop(q).(queueEntry).cell
+		if done[current] {
+			continue
+		}
+		done[current] = true
+		openCount--
+		result.Expanded++
+		if current == endIndex {
+			break
+		}
+		neighbors = m.openNeighbors(current, neighbors[:0])
+		for _, n := range neighbors {
+			if done[n] {
+				continue
+			}
+			cost := costs[current] + m.moveCost(current, n)
+			if (costs[n] >= 0) && (costs[n] <= cost) {
+				continue
+			}
+			if costs[n] < 0 {
+				openCount++
+			}
+			costs[n] = cost
+			parents[n] = current
+			estimate := float64(cost) + h.Estimate(m.cellPoint(n), goal)*scale
+			heap.Push(q, queueEntry{n, estimate})
+		}
+		if result.PeakFrontier < openCount {
+			result.PeakFrontier = openCount
+		}
+	}
+	path := pathFromParents(costs, parents, endIndex)
+	if path == nil {
+		start := m.cellPoint(startIndex)
+		return nil, 0, fmt.Errorf("Cell (%d, %d) can't be reached from "+
+			"cell (%d, %d)", goal.X, goal.Y, start.X, start.Y)
+	}
+	return path, costs[endIndex], nil
+}
+
+// Finds the cheapest solution using A* search with the given heuristic,
+// passing through any waypoints in order. Move costs are the same as those
+// used by CheapestSolution, and the heuristic is scaled by the cheapest
+// possible move. The heuristics may overestimate the remaining cost in mazes
+// with portals, in which case the path may not be the cheapest.
+func (m *GridMaze) SolveAStar(h Heuristic) (*AStarResult, error) {
+	if h > HeuristicEuclidean {
+		return nil, fmt.Errorf("Invalid heuristic: %s", h)
+	}
+	startTime := time.Now()
+	toReturn := &AStarResult{}
+	stops := m.routeStops()
+	route := []int{m.startCellIndex}
+	for i := 1; i < len(stops); i++ {
+		leg, cost, e := m.aStarPath(stops[i-1], stops[i], h, toReturn)
+		if e != nil {
+			return nil, e
+		}
+		route = append(route, leg[1:]...)
+		toReturn.Cost += cost
+	}
+	toReturn.Path = make([]image.Point, len(route))
+	for i, index := range route {
+		toReturn.Path[i] = m.cellPoint(index)
+	}
+	toReturn.Elapsed = time.Since(startTime)
+	return toReturn, nil
+}
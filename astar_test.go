@@ -0,0 +1,56 @@
+package maze
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSolveAStar(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		erosion int
+		seeds   []int64
+	}{
+		{"perfect", 12, 0, []int64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"braided", 20, 5, []int64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"heavily braided", 40, 10, []int64{1, 2, 3, 141, 271}},
+	}
+	heuristics := []Heuristic{HeuristicZero, HeuristicManhattan,
+		HeuristicEuclidean}
+	for _, test := range tests {
+		for _, seed := range test.seeds {
+			m := newTestMaze(t, test.size, test.size, seed, test.erosion)
+			rng := rand.New(rand.NewSource(seed))
+			randomizeTerrain(m, rng, 20, 50)
+			expected := bruteForceRouteCost(m)
+			for _, h := range heuristics {
+				result, e := m.SolveAStar(h)
+				if e != nil {
+					t.Fatalf("%s maze, seed %d, %s heuristic: failed "+
+						"solving: %s", test.name, seed, h, e)
+				}
+				if result.Cost != expected {
+					t.Fatalf("%s maze, seed %d, %s heuristic: got cost %d, "+
+						"expected %d", test.name, seed, h, result.Cost,
+						expected)
+				}
+				checkWalk(t, m, result.Path, m.StartCell(), m.EndCell())
+				checkPathCost(t, m, result.Path, result.Cost)
+				if (result.Expanded <= 0) || (result.PeakFrontier <= 0) {
+					t.Fatalf("%s maze, seed %d, %s heuristic: bad stats: "+
+						"%d expanded, peak frontier %d", test.name, seed, h,
+						result.Expanded, result.PeakFrontier)
+				}
+			}
+		}
+	}
+}
+
+func TestSolveAStarInvalidHeuristic(t *testing.T) {
+	m := newTestMaze(t, 4, 4, 1, 0)
+	_, e := m.SolveAStar(HeuristicEuclidean + 1)
+	if e == nil {
+		t.Fatalf("Didn't get an error using an invalid heuristic")
+	}
+}
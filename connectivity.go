@@ -0,0 +1,307 @@
+package maze
+
+// This file contains functions for finding and fixing template masks with
+// regions that can't be reached from the rest of the maze.
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// A region of template cells that are connected to each other, but not to any
+// other cells. Two cells are connected if they share an edge and neither is
+// excluded or a solid wall.
+type TemplateComponent struct {
+	// Every cell in the region, in left-to-right, top-to-bottom order.
+	Cells []image.Point
+	// The smallest rectangle, in cell coordinates, containing the region.
+	Bounds image.Rectangle
+}
+
+// Returned when a template mask contains regions that aren't connected to the
+// main region, i.e. the one containing the start cell.
+type DisconnectedTemplateError struct {
+	// The region containing the start cell.
+	Main TemplateComponent
+	// The regions that can't be reached from the main region.
+	Islands []TemplateComponent
+}
+
+func (e *DisconnectedTemplateError) Error() string {
+	// List at most this many islands, since large templates can contain a
+	// lot of them.
+	listLimit := 10
+	var b strings.Builder
+	fmt.Fprintf(&b, "The template contains %d region(s) that aren't "+
+		"connected to the region containing the start cell: ",
+		len(e.Islands))
+	for i, island := range e.Islands {
+		if i == listLimit {
+			fmt.Fprintf(&b, ", and %d more", len(e.Islands)-listLimit)
+			break
+		}
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		r := island.Bounds
+		fmt.Fprintf(&b, "%d cell(s) at (%d, %d)-(%d, %d)", len(island.Cells),
+			r.Min.X, r.Min.Y, r.Max.X-1, r.Max.Y-1)
+	}
+	return b.String()
+}
+
+// Determines how NewGridMazeFromMask handles templates with regions that
+// can't be reached from the start cell.
+type IslandMode uint8
+
+const (
+	// Return a *DisconnectedTemplateError describing the unreachable regions.
+	IslandsError IslandMode = iota
+	// Exclude every cell in each unreachable region from the maze.
+	IslandsExclude
+	// Connect each unreachable region to the rest of the maze by converting
+	// the fewest possible excluded or solid wall cells into normal cells.
+	IslandsBridge
+)
+
+func (m IslandMode) String() string {
+	switch m {
+	case IslandsError:
+		return "error"
+	case IslandsExclude:
+		return "exclude"
+	case IslandsBridge:
+		return "bridge"
+	}
+	return fmt.Sprintf("Unknown IslandMode: %d", uint8(m))
+}
+
+// Returns true if paths can pass through the template cell at the index.
+func (t *TemplateMask) passable(cellIndex int) bool {
+	cellType := t.cells[cellIndex]
+	return (cellType != TemplateExcluded) && (cellType != TemplateWall)
+}
+
+// Appends the indices of the up to four cells sharing an edge with the given
+// cell to dst, and returns the new slice.
+func (t *TemplateMask) adjacentCells(cellIndex int, dst []int) []int {
+	col := cellIndex % t.width
+	row := cellIndex / t.width
+	if col > 0 {
+		dst = append(dst, cellIndex-1)
+	}
+	if row > 0 {
+		dst = append(dst, cellIndex-t.width)
+	}
+	if col < (t.width - 1) {
+		dst = append(dst, cellIndex+1)
+	}
+	if row < (t.height - 1) {
+		dst = append(dst, cellIndex+t.width)
+	}
+	return dst
+}
+
+// Labels each passable cell with the index of its connected region. Returns
+// the labels, with -1 for cells that aren't passable, and the number of
+// regions.
+func (t *TemplateMask) labelComponents() ([]int, int) {
+	labels := make([]int, len(t.cells))
+	for i := range labels {
+		labels[i] = -1
+	}
+	count := 0
+	stack := make([]int, 0, 64)
+	var adjacent []int
+	for i := range t.cells {
+		if (labels[i] >= 0) || !t.passable(i) {
+			continue
+		}
+		labels[i] = count
+		stack = append(stack[:0], i)
+		for len(stack) != 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			adjacent = t.adjacentCells(current, adjacent[:0])
+			for _, n := range adjacent {
+				if (labels[n] >= 0) || !t.passable(n) {
+					continue
+				}
+				labels[n] = count
+				stack = append(stack, n)
+			}
+		}
+		count++
+	}
+	return labels, count
+}
+
+// Returns the index of the cell that will be used as the start when the
+// template is turned into a maze, or at least a start candidate in the same
+// region. Returns -1 if there isn't one.
+func (t *TemplateMask) startIndex() int {
+	for i, cellType := range t.cells {
+		if cellType == TemplateStart {
+			return i
+		}
+	}
+	if t.passable(0) {
+		return 0
+	}
+	return -1
+}
+
+// Returns the label of the main region: the one containing the start cell,
+// or the largest region if there's no usable start cell.
+func (t *TemplateMask) mainComponent(labels []int, count int) int {
+	start := t.startIndex()
+	if start >= 0 {
+		return labels[start]
+	}
+	sizes := make([]int, count)
+	for _, label := range labels {
+		if label >= 0 {
+			sizes[label]++
+		}
+	}
+	toReturn := 0
+	for i, size := range sizes {
+		if size > sizes[toReturn] {
+			toReturn = i
+		}
+	}
+	return toReturn
+}
+
+// Returns every connected region in the mask. The main region, containing
+// the start cell, is always first.
+func (t *TemplateMask) Components() []TemplateComponent {
+	labels, count := t.labelComponents()
+	if count == 0 {
+		return nil
+	}
+	main := t.mainComponent(labels, count)
+	// Reorder labels so the main region comes first.
+	order := func(label int) int {
+		if label == main {
+			return 0
+		}
+		if label < main {
+			return label + 1
+		}
+		return label
+	}
+	toReturn := make([]TemplateComponent, count)
+	for i, label := range labels {
+		if label < 0 {
+			continue
+		}
+		c := &(toReturn[order(label)])
+		p := image.Pt(i%t.width, i/t.width)
+		cellRect := image.Rect(p.X, p.Y, p.X+1, p.Y+1)
+		if len(c.Cells) == 0 {
+			c.Bounds = cellRect
+		} else {
+			c.Bounds = c.Bounds.Union(cellRect)
+		}
+		c.Cells = append(c.Cells, p)
+	}
+	return toReturn
+}
+
+// Returns a *DisconnectedTemplateError if any part of the mask can't be
+// reached from the start cell. Returns nil otherwise.
+func (t *TemplateMask) CheckConnectivity() error {
+	components := t.Components()
+	if len(components) <= 1 {
+		return nil
+	}
+	return &DisconnectedTemplateError{
+		Main:    components[0],
+		Islands: components[1:],
+	}
+}
+
+// Modifies the mask so that every passable cell is reachable from the start
+// cell, using the given mode. Returns a *DisconnectedTemplateError if the
+// mode is IslandsError and the mask contains unreachable regions.
+func (t *TemplateMask) ResolveIslands(mode IslandMode) error {
+	switch mode {
+	case IslandsError:
+		return t.CheckConnectivity()
+	case IslandsExclude:
+		t.excludeIslands()
+		return nil
+	case IslandsBridge:
+		return t.bridgeIslands()
+	}
+	return fmt.Errorf("Invalid island mode: %s", mode)
+}
+
+// Marks every cell outside of the main region as excluded.
+func (t *TemplateMask) excludeIslands() {
+	labels, count := t.labelComponents()
+	if count <= 1 {
+		return
+	}
+	main := t.mainComponent(labels, count)
+	for i, label := range labels {
+		if (label >= 0) && (label != main) {
+			t.cells[i] = TemplateExcluded
+		}
+	}
+}
+
+// Repeatedly finds the shortest path from the main region to any other
+// region, and converts the excluded or wall cells along it to normal cells,
+// until only one region remains.
+func (t *TemplateMask) bridgeIslands() error {
+	parents := make([]int, len(t.cells))
+	queue := make([]int, 0, len(t.cells))
+	var adjacent []int
+	for {
+		labels, count := t.labelComponents()
+		if count <= 1 {
+			return nil
+		}
+		main := t.mainComponent(labels, count)
+		for i := range parents {
+			parents[i] = -2
+		}
+		queue = queue[:0]
+		for i, label := range labels {
+			if label == main {
+				parents[i] = -1
+				queue = append(queue, i)
+			}
+		}
+		// Breadth-first search outward from the main region until we find a
+		// cell belonging to another region.
+		found := -1
+	SearchLoop:
+		for len(queue) != 0 {
+			current := queue[0]
+			queue = queue[1:]
+			adjacent = t.adjacentCells(current, adjacent[:0])
+			for _, n := range adjacent {
+				if parents[n] != -2 {
+					continue
+				}
+				parents[n] = current
+				if labels[n] >= 0 {
+					found = n
+					break SearchLoop
+				}
+				queue = append(queue, n)
+			}
+		}
+		if found < 0 {
+			return fmt.Errorf("Internal error: failed to bridge template " +
+				"regions")
+		}
+		for i := parents[found]; parents[i] >= 0; i = parents[i] {
+			t.cells[i] = TemplateNormal
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package maze
+
+import (
+	"testing"
+)
+
+// Builds a template mask from rows of characters: '.' for normal cells, '#'
+// for excluded cells, 'S' for start cells, and 'X' for solid walls.
+func maskFromRows(t *testing.T, rows ...string) *TemplateMask {
+	toReturn, e := NewTemplateMask(len(rows[0]), len(rows))
+	if e != nil {
+		t.Fatalf("Failed creating template mask: %s", e)
+	}
+	types := map[byte]TemplateCellType{
+		'.': TemplateNormal,
+		'#': TemplateExcluded,
+		'S': TemplateStart,
+		'X': TemplateWall,
+	}
+	for row, line := range rows {
+		for col := 0; col < len(line); col++ {
+			toReturn.Set(col, row, types[line[col]])
+		}
+	}
+	return toReturn
+}
+
+// A template with a main region around the start, and three islands: a 2x2
+// block to the right, a pair of cells at the bottom left, and a single cell
+// next to a solid wall at the bottom right.
+var islandRows = []string{
+	"S.#..",
+	"..#..",
+	"#####",
+	"..#.X",
+}
+
+func TestCheckConnectivity(t *testing.T) {
+	mask := maskFromRows(t, "S..", ".X.", "...")
+	e := mask.CheckConnectivity()
+	if e != nil {
+		t.Fatalf("Connected template was reported as disconnected: %s", e)
+	}
+	mask = maskFromRows(t, islandRows...)
+	e = mask.CheckConnectivity()
+	disconnected, ok := e.(*DisconnectedTemplateError)
+	if !ok {
+		t.Fatalf("Didn't get a *DisconnectedTemplateError: %v", e)
+	}
+	if len(disconnected.Main.Cells) != 4 {
+		t.Fatalf("Main region has %d cells, expected 4",
+			len(disconnected.Main.Cells))
+	}
+	expectedSizes := []int{4, 2, 1}
+	if len(disconnected.Islands) != len(expectedSizes) {
+		t.Fatalf("Got %d islands, expected %d", len(disconnected.Islands),
+			len(expectedSizes))
+	}
+	for i, size := range expectedSizes {
+		if len(disconnected.Islands[i].Cells) != size {
+			t.Fatalf("Island %d has %d cells, expected %d", i,
+				len(disconnected.Islands[i].Cells), size)
+		}
+	}
+	if disconnected.Islands[0].Bounds.Min.X != 3 {
+		t.Fatalf("Got bounds %s for the first island",
+			disconnected.Islands[0].Bounds)
+	}
+}
+
+func TestResolveIslands(t *testing.T) {
+	tests := []struct {
+		mode IslandMode
+		// The number of cells that should change type.
+		changed int
+		// The number of cells that should be excluded afterwards.
+		excluded int
+	}{
+		{IslandsExclude, 7, 15},
+		{IslandsBridge, 3, 5},
+	}
+	for _, test := range tests {
+		original := maskFromRows(t, islandRows...)
+		mask := maskFromRows(t, islandRows...)
+		e := mask.ResolveIslands(test.mode)
+		if e != nil {
+			t.Fatalf("Failed resolving islands with mode %s: %s", test.mode,
+				e)
+		}
+		e = mask.CheckConnectivity()
+		if e != nil {
+			t.Fatalf("Mode %s left the template disconnected: %s",
+				test.mode, e)
+		}
+		changed, excluded := 0, 0
+		for i := range mask.cells {
+			if mask.cells[i] != original.cells[i] {
+				changed++
+			}
+			if mask.cells[i] == TemplateExcluded {
+				excluded++
+			}
+		}
+		if (changed != test.changed) || (excluded != test.excluded) {
+			t.Fatalf("Mode %s changed %d cells, leaving %d excluded; "+
+				"expected %d and %d", test.mode, changed, excluded,
+				test.changed, test.excluded)
+		}
+		if mask.At(0, 0) != TemplateStart {
+			t.Fatalf("Mode %s changed the start cell", test.mode)
+		}
+	}
+	mask := maskFromRows(t, islandRows...)
+	_, ok := mask.ResolveIslands(IslandsError).(*DisconnectedTemplateError)
+	if !ok {
+		t.Fatalf("IslandsError mode didn't return a " +
+			"*DisconnectedTemplateError")
+	}
+	if mask.ResolveIslands(IslandsBridge+1) == nil {
+		t.Fatalf("Didn't get an error using an invalid island mode")
+	}
+}
@@ -0,0 +1,102 @@
+package maze
+
+// This file contains functions for generating mazes that satisfy given
+// difficulty constraints.
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// A predicate on a generated maze. Returns true if the maze is acceptable.
+// The stats are those of the maze being checked.
+type MazeConstraint func(m *GridMaze, stats *MazeStats) bool
+
+// Returns a constraint requiring the shortest solution to take at least n
+// moves.
+func MinSolutionLength(n int) MazeConstraint {
+	return func(m *GridMaze, stats *MazeStats) bool {
+		return stats.SolutionLength >= n
+	}
+}
+
+// Returns a constraint requiring the shortest solution to take at most n
+// moves. Unsolvable mazes never satisfy this constraint.
+func MaxSolutionLength(n int) MazeConstraint {
+	return func(m *GridMaze, stats *MazeStats) bool {
+		return (stats.SolutionLength >= 0) && (stats.SolutionLength <= n)
+	}
+}
+
+// Returns a constraint requiring the maze to contain at least n dead ends.
+func MinDeadEnds(n int) MazeConstraint {
+	return func(m *GridMaze, stats *MazeStats) bool {
+		return stats.DeadEnds >= n
+	}
+}
+
+// Returns a constraint requiring the maze to contain at most n dead ends.
+func MaxDeadEnds(n int) MazeConstraint {
+	return func(m *GridMaze, stats *MazeStats) bool {
+		return stats.DeadEnds <= n
+	}
+}
+
+// Returns a constraint requiring at least n decision points along the
+// solution path.
+func MinDecisionPoints(n int) MazeConstraint {
+	return func(m *GridMaze, stats *MazeStats) bool {
+		return stats.DecisionPoints >= n
+	}
+}
+
+// Returns a constraint requiring at most n decision points along the solution
+// path.
+func MaxDecisionPoints(n int) MazeConstraint {
+	return func(m *GridMaze, stats *MazeStats) bool {
+		return stats.DecisionPoints <= n
+	}
+}
+
+// Repeatedly regenerates the maze until it satisfies every given constraint,
+// making at most maxAttempts attempts. The first attempt uses the given seed,
+// and each later attempt uses a seed derived from it, so the same arguments
+// always produce the same maze. If the given seed is not positive, a new seed
+// will be selected based on the current time in nanoseconds. Returns the seed
+// that produced the accepted maze, which can be passed to RegenerateFromSeed
+// to recreate it. Returns an error if no attempt succeeded, in which case the
+// maze is left in the state of the final attempt.
+func (m *GridMaze) GenerateWithConstraints(seed int64, maxAttempts int,
+	constraints ...MazeConstraint) (int64, error) {
+	if maxAttempts < 1 {
+		return 0, fmt.Errorf("At least one attempt is required")
+	}
+	if seed <= 0 {
+		seed = time.Now().UnixNano()
+	}
+	seedRNG := rand.New(rand.NewSource(seed))
+	attemptSeed := seed
+AttemptLoop:
+	for i := 0; i < maxAttempts; i++ {
+		if i != 0 {
+			attemptSeed = seedRNG.Int63()
+		}
+		e := m.RegenerateFromSeed(attemptSeed)
+		if e != nil {
+			return 0, fmt.Errorf("Error generating maze: %w", e)
+		}
+		stats, e := m.Stats()
+		if e != nil {
+			return 0, fmt.Errorf("Error computing maze stats: %w", e)
+		}
+		for _, c := range constraints {
+			if !c(m, stats) {
+				continue AttemptLoop
+			}
+		}
+		return attemptSeed, nil
+	}
+	return 0, fmt.Errorf("No maze satisfied the constraints after %d "+
+		"attempts", maxAttempts)
+}
@@ -8,8 +8,11 @@ import (
 	"github.com/yalue/maze"
 	"image"
 	"image/color"
+	_ "image/jpeg"
 	"image/png"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -112,19 +115,24 @@ func drawMazeDecorations(m maze.Maze) (*image.RGBA, error) {
 	blueColor := color.RGBA{100, 120, 255, 255}
 	greenColor := color.RGBA{40, 180, 70, 255}
 
-	startArrow := getOutlinedArrow(info.StartAngle, greenColor)
-	startArrowPos := getArrowTopLeft(info.StartPoint, info.StartAngle,
-		false)
-	e = decorated.AddImage(startArrow, startArrowPos)
-	if e != nil {
-		return nil, fmt.Errorf("Error adding start arrow: %w", e)
+	// The info.Starts and info.Ends lists include the main start and end,
+	// along with any additional entrances and exits.
+	for _, start := range info.Starts {
+		startArrow := getOutlinedArrow(start.Angle, greenColor)
+		startArrowPos := getArrowTopLeft(start.Point, start.Angle, false)
+		e = decorated.AddImage(startArrow, startArrowPos)
+		if e != nil {
+			return nil, fmt.Errorf("Error adding start arrow: %w", e)
+		}
 	}
 
-	endArrow := getOutlinedArrow(info.EndAngle, blueColor)
-	endArrowPos := getArrowTopLeft(info.EndPoint, info.EndAngle, true)
-	e = decorated.AddImage(endArrow, endArrowPos)
-	if e != nil {
-		return nil, fmt.Errorf("Error adding end arrow: %w", e)
+	for _, end := range info.Ends {
+		endArrow := getOutlinedArrow(end.Angle, blueColor)
+		endArrowPos := getArrowTopLeft(end.Point, end.Angle, true)
+		e = decorated.AddImage(endArrow, endArrowPos)
+		if e != nil {
+			return nil, fmt.Errorf("Error adding end arrow: %w", e)
+		}
 	}
 
 	// TODO (next): Add clipart at start and/or end
@@ -185,11 +193,135 @@ func generateMetaMaze(level int, randomSeed int64) (maze.Maze, error) {
 	return m, nil
 }
 
+// Returns the palette to use for template images, or nil if the standard
+// template colors should be used as-is. If a palette is returned, also prints
+// a report on how the template's colors were interpreted.
+func getTemplatePalette(pic image.Image,
+	tolerance int) (*maze.TemplatePalette, error) {
+	if tolerance < 0 {
+		return nil, nil
+	}
+	if tolerance > 255 {
+		return nil, fmt.Errorf("Invalid template tolerance: %d", tolerance)
+	}
+	palette := maze.DefaultTemplatePalette()
+	palette.Nearest = true
+	for i := range palette.Entries {
+		palette.Entries[i].Tolerance = uint8(tolerance)
+	}
+	fmt.Printf("Template colors:\n%s\n", palette.Validate(pic))
+	return palette, nil
+}
+
+// Generates a maze from the given template image. If cellsWide and cellsHigh
+// are positive, the template is resized to the given number of cells.
+// Otherwise, each pixel in the template becomes one cell.
+func loadTemplateMaze(pic image.Image, tolerance, cellsWide, cellsHigh int,
+	islandMode maze.IslandMode, randomSeed int64) (*maze.GridMaze, error) {
+	palette, e := getTemplatePalette(pic, tolerance)
+	if e != nil {
+		return nil, e
+	}
+	var mask *maze.TemplateMask
+	if (cellsWide > 0) && (cellsHigh > 0) {
+		mask, e = maze.DownsampleTemplate(pic, cellsWide, cellsHigh,
+			&maze.DownsampleOptions{
+				Palette: palette,
+			})
+	} else {
+		mask, e = maze.TemplateMaskFromImage(pic, palette)
+	}
+	if e != nil {
+		return nil, e
+	}
+	e = mask.ResolveIslands(islandMode)
+	if e != nil {
+		return nil, e
+	}
+	return maze.NewGridMazeFromMask(mask, randomSeed)
+}
+
+// Generates a maze from the plain-text template file at the given path.
+func loadTextTemplateMaze(path string, islandMode maze.IslandMode,
+	randomSeed int64) (*maze.GridMaze, error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, fmt.Errorf("Error opening template %s: %w", path, e)
+	}
+	defer f.Close()
+	mask, e := maze.ParseTextTemplate(f)
+	if e != nil {
+		return nil, fmt.Errorf("Error parsing template %s: %w", path, e)
+	}
+	e = mask.ResolveIslands(islandMode)
+	if e != nil {
+		return nil, e
+	}
+	return maze.NewGridMazeFromMask(mask, randomSeed)
+}
+
+// Writes the maze's passage graph to the given path. The format is chosen
+// based on the file's extension: .dot, .graphml, or .json.
+func writeGraph(m *maze.GridMaze, path string, collapse bool) error {
+	opts := &maze.GraphExportOptions{
+		CollapseCorridors: collapse,
+	}
+	var write func(f *os.File) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dot", ".gv":
+		write = func(f *os.File) error { return m.WriteDOT(f, opts) }
+	case ".graphml":
+		write = func(f *os.File) error { return m.WriteGraphML(f, opts) }
+	case ".json":
+		write = func(f *os.File) error {
+			return m.WriteAdjacencyJSON(f, opts)
+		}
+	default:
+		return fmt.Errorf("Unsupported graph file extension: %s", path)
+	}
+	f, e := os.Create(path)
+	if e != nil {
+		return fmt.Errorf("Error creating %s: %w", path, e)
+	}
+	e = write(f)
+	if e != nil {
+		f.Close()
+		return e
+	}
+	// Buffered data may not reach the file until it's closed, so closing
+	// can fail even after a successful write.
+	e = f.Close()
+	if e != nil {
+		return fmt.Errorf("Error closing %s: %w", path, e)
+	}
+	return nil
+}
+
+// Maps the values accepted by the -template_islands flag to island modes.
+var islandModes = map[string]maze.IslandMode{
+	"error":   maze.IslandsError,
+	"exclude": maze.IslandsExclude,
+	"bridge":  maze.IslandsBridge,
+}
+
+// Maps the values accepted by the -endpoint_mode flag to endpoint modes.
+var endpointModes = map[string]maze.EndpointMode{
+	"default":          maze.EndpointsDefault,
+	"longest":          maze.EndpointsLongestPath,
+	"longest_border":   maze.EndpointsLongestBorderPath,
+	"longest_template": maze.EndpointsLongestTemplatePath,
+}
+
 func run() int {
 	var cellWidth, cellsWide, cellsHigh, erodeAmount, metaMaze int
+	var templateTolerance int
 	var randomSeed int64
-	var showSolution bool
-	var outFilename, templateImage string
+	var showSolution, showStats, resizeTemplate, cheapestSolution bool
+	var outFilename, templateImage, endpointMode, templateIslands string
+	var text, templateText, graphFile string
+	var collapseCorridors bool
+	var allEndpoints bool
+	var keepStart, keyCount, oneWayCount, portalCount int
 	flag.IntVar(&cellsWide, "cells_wide", 20,
 		"The width of the maze, in grid cells.")
 	flag.IntVar(&cellsHigh, "cells_high", 20,
@@ -202,6 +334,11 @@ func run() int {
 		"If positive, specifies the random seed to use.")
 	flag.BoolVar(&showSolution, "show_solution", false,
 		"If set, shows the solution of the maze.")
+	flag.BoolVar(&cheapestSolution, "cheapest_solution", false,
+		"If set along with -show_solution, shows the solution with the "+
+			"lowest terrain cost rather than the fewest moves.")
+	flag.BoolVar(&showStats, "stats", false,
+		"If set, prints metrics about the maze's layout.")
 	flag.IntVar(&metaMaze, "meta_maze", 0,
 		"If positive, does a \"meta\" maze. Ignores width specifications."+
 			" If used, keep the value low.")
@@ -209,30 +346,96 @@ func run() int {
 		"The name of the .png file to which the maze will be saved.")
 	flag.StringVar(&templateImage, "template_image", "",
 		"An optional path to a PNG-format image to use as a layout "+
-			"template. Wil ignore cells_wide and cells_high if used.")
+			"template. Wil ignore cells_wide and cells_high if used, unless "+
+			"-resize_template is set.")
+	flag.BoolVar(&resizeTemplate, "resize_template", false,
+		"If set, the template image is resized to cells_wide x cells_high "+
+			"cells, rather than using one cell per pixel.")
+	flag.StringVar(&templateText, "template_text", "",
+		"An optional path to a plain-text template, using '#' for excluded "+
+			"cells, '.' for normal cells, 'S' and 'E' for start and end "+
+			"candidates, 'W' for waypoints, 'R' for rooms, 'X' for "+
+			"walls, and 'M', '~', and 'H' for mud, water, and stairs.")
+	flag.StringVar(&text, "text", "",
+		"If set, generates a maze shaped like the given text. Ignores "+
+			"cells_wide and cells_high if used.")
+	flag.StringVar(&templateIslands, "template_islands", "error",
+		"What to do with parts of the template that can't be reached from "+
+			"the start. Must be one of \"error\", \"exclude\", or "+
+			"\"bridge\".")
+	flag.IntVar(&templateTolerance, "template_tolerance", -1,
+		"If 0 or more, template colors within this distance (0-255) of a "+
+			"standard template color are accepted, and other colors are "+
+			"treated as the nearest standard color. Useful for JPEG or "+
+			"anti-aliased templates.")
+	flag.BoolVar(&allEndpoints, "all_endpoints", false,
+		"If set, every start and end candidate in the template is used as "+
+			"an entrance or exit.")
+	flag.IntVar(&keepStart, "keep_start", -1,
+		"If 0 or more, adds walls so that only the entrance with this index "+
+			"leads to an exit. Intended for use with -all_endpoints.")
+	flag.IntVar(&portalCount, "portals", 0,
+		"The number of portal pairs to add. Portals are placed so that "+
+			"they lengthen the solution.")
+	flag.IntVar(&oneWayCount, "one_way", 0,
+		"The number of passages to make one-way. The maze will remain "+
+			"solvable.")
+	flag.IntVar(&keyCount, "keys", 0,
+		"The number of locked doors to place along the solution. A key "+
+			"for each door is placed somewhere it can be reached first.")
+	flag.StringVar(&graphFile, "graph_file", "",
+		"If set, writes the maze's passage graph to this path. The format "+
+			"depends on the extension: .dot, .graphml, or .json.")
+	flag.BoolVar(&collapseCorridors, "collapse_corridors", false,
+		"If set along with -graph_file, corridors are collapsed into "+
+			"weighted edges between junctions and dead ends.")
+	flag.StringVar(&endpointMode, "endpoint_mode", "default",
+		"How to choose the start and end cells. Must be one of \"default\", "+
+			"\"longest\", \"longest_border\", or \"longest_template\". "+
+			"The \"longest\" modes pick the two cells farthest apart.")
 	flag.Parse()
 	if (cellsWide < 1) || (cellsHigh < 1) || (outFilename == "") {
 		fmt.Println("Invalid or missing argument.")
 		fmt.Println("Run with -help for more information.")
 		return 1
 	}
+	islandMode, ok := islandModes[templateIslands]
+	if !ok {
+		fmt.Printf("Invalid template island mode: %s\n", templateIslands)
+		return 1
+	}
 	var e error
 	var m *maze.GridMaze
 	if templateImage != "" {
-		f, e := os.Open(templateImage)
+		// Avoid shadowing e, so generation errors are reported below.
+		var f *os.File
+		var pic image.Image
+		f, e = os.Open(templateImage)
 		if e != nil {
 			fmt.Printf("Error opening template image %s: %s\n", templateImage,
 				e)
 			return 1
 		}
-		pic, _, e := image.Decode(f)
+		pic, _, e = image.Decode(f)
 		f.Close()
 		if e != nil {
 			fmt.Printf("Error parsing template image %s: %s\n", templateImage,
 				e)
 			return 1
 		}
-		m, e = maze.NewGridMazeFromTemplate(pic, randomSeed)
+		if !resizeTemplate {
+			cellsWide, cellsHigh = 0, 0
+		}
+		m, e = loadTemplateMaze(pic, templateTolerance, cellsWide, cellsHigh,
+			islandMode, randomSeed)
+	} else if templateText != "" {
+		m, e = loadTextTemplateMaze(templateText, islandMode, randomSeed)
+	} else if text != "" {
+		var mask *maze.TemplateMask
+		mask, e = maze.TextTemplate(text, nil)
+		if e == nil {
+			m, e = maze.NewGridMazeFromMask(mask, randomSeed)
+		}
 	} else if metaMaze > 0 {
 		var tmp maze.Maze
 		tmp, e = generateMetaMaze(metaMaze, randomSeed)
@@ -246,6 +449,23 @@ func run() int {
 		fmt.Printf("Failed generating maze: %s\n", e)
 		return 1
 	}
+	mode, ok := endpointModes[endpointMode]
+	if !ok {
+		fmt.Printf("Invalid endpoint mode: %s\n", endpointMode)
+		return 1
+	}
+	e = m.SetEndpointMode(mode)
+	if e != nil {
+		fmt.Printf("Error choosing maze endpoints: %s\n", e)
+		return 1
+	}
+	if allEndpoints {
+		e = m.UseAllEndpointCandidates()
+		if e != nil {
+			fmt.Printf("Error using all endpoints: %s\n", e)
+			return 1
+		}
+	}
 	tmp := m.GetInfo()
 	fmt.Printf("Generated %s OK.\n", tmp.DebugInfo)
 	if erodeAmount > 0 {
@@ -258,14 +478,69 @@ func run() int {
 			}
 		}
 	}
+	if keepStart >= 0 {
+		e = m.IsolateStarts(keepStart)
+		if e != nil {
+			fmt.Printf("Error isolating entrances: %s\n", e)
+			return 1
+		}
+	}
+	if portalCount > 0 {
+		_, e = m.PlacePortals(portalCount, randomSeed)
+		if e != nil {
+			fmt.Printf("Error placing portals: %s\n", e)
+			return 1
+		}
+	}
+	if oneWayCount > 0 {
+		_, e = m.AddOneWayPassages(oneWayCount, randomSeed)
+		if e != nil {
+			fmt.Printf("Error adding one-way passages: %s\n", e)
+			return 1
+		}
+	}
+	if keyCount > 0 {
+		e = m.PlaceKeysAndDoors(keyCount, randomSeed)
+		if e != nil {
+			fmt.Printf("Error placing keys and doors: %s\n", e)
+			return 1
+		}
+		keySolution, e := m.SolveKeysAndDoors()
+		if e != nil {
+			fmt.Printf("Error solving keys and doors: %s\n", e)
+			return 1
+		}
+		fmt.Printf("Keys are collected in order %v, taking %d moves.\n",
+			keySolution.Order, keySolution.Length)
+	}
+	if showStats {
+		stats, e := m.Stats()
+		if e != nil {
+			fmt.Printf("Error computing maze stats: %s\n", e)
+			return 1
+		}
+		fmt.Printf("Maze stats:\n%s\n", stats)
+	}
 	if showSolution {
 		fmt.Printf("Finding solution to the maze.\n")
-		e = m.ShowSolution(true)
+		if cheapestSolution {
+			e = m.ShowCheapestSolution()
+		} else {
+			e = m.ShowSolution(true)
+		}
 		if e != nil {
 			fmt.Printf("Error finding solution: %s\n", e)
 			return 1
 		}
 	}
+	if graphFile != "" {
+		e = writeGraph(m, graphFile, collapseCorridors)
+		if e != nil {
+			fmt.Printf("Error writing maze graph: %s\n", e)
+			return 1
+		}
+		fmt.Printf("Graph %s written OK.\n", graphFile)
+	}
 	e = m.SetCellPixelsWide(cellWidth)
 	if e != nil {
 		fmt.Printf("Error setting maze cell width: %s\n", e)
@@ -0,0 +1,258 @@
+package maze
+
+// This file contains functions for computing distances between cells in a
+// GridMaze, and for visualizing them.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Returns the number of cells in each row of the maze.
+func (m *GridMaze) CellsWide() int {
+	return m.width
+}
+
+// Returns the number of cells in each column of the maze.
+func (m *GridMaze) CellsHigh() int {
+	return m.height
+}
+
+// Returns the column and row of the maze's start cell.
+func (m *GridMaze) StartCell() image.Point {
+	return m.cellPoint(m.startCellIndex)
+}
+
+// Returns the column and row of the maze's end cell.
+func (m *GridMaze) EndCell() image.Point {
+	return m.cellPoint(m.endCellIndex)
+}
+
+// Appends the indices of every cell that can be reached from the given cell
+// in a single move to dst, and returns the new slice. Unlike passageNeighbors,
+// this respects one-way passages and includes the cell linked by a portal.
+func (m *GridMaze) openNeighbors(cellIndex int, dst []int) []int {
+	c := &(m.cells[cellIndex])
+	for dir := 0; dir < 4; dir++ {
+		if c.walls[dir] || c.noExit[dir] {
+			continue
+		}
+		neighbor := m.neighborInDirection(cellIndex, dir)
+		if (neighbor < 0) || m.cells[neighbor].state.excluded() {
+			continue
+		}
+		dst = append(dst, neighbor)
+	}
+	partner := m.portalPartner(cellIndex)
+	if (partner >= 0) && !m.cells[partner].state.excluded() {
+		dst = append(dst, partner)
+	}
+	return dst
+}
+
+// Appends the indices of every cell connected to the given cell by an open
+// passage to dst, and returns the new slice. One-way passages are included
+// regardless of their direction.
+func (m *GridMaze) passageNeighbors(cellIndex int, dst []int) []int {
+	c := &(m.cells[cellIndex])
+	for dir := 0; dir < 4; dir++ {
+		if c.walls[dir] {
+			continue
+		}
+		// A missing wall on the maze's border (i.e. at an endpoint) doesn't
+		// lead anywhere.
+		neighbor := m.neighborInDirection(cellIndex, dir)
+		if neighbor < 0 {
+			continue
+		}
+		if m.cells[neighbor].state.excluded() {
+			continue
+		}
+		dst = append(dst, neighbor)
+	}
+	return dst
+}
+
+// Appends the indices of every cell connected to the given cell by an open
+// passage or a portal to dst, and returns the new slice. One-way passages are
+// included regardless of their direction.
+func (m *GridMaze) graphNeighbors(cellIndex int, dst []int) []int {
+	dst = m.passageNeighbors(cellIndex, dst)
+	partner := m.portalPartner(cellIndex)
+	if (partner >= 0) && !m.cells[partner].state.excluded() {
+		dst = append(dst, partner)
+	}
+	return dst
+}
+
+// Returns a slice containing the number of moves required to reach each cell
+// from the cell at startIndex, as computed by a breadth-first search. Cells
+// that can't be reached contain -1.
+func (m *GridMaze) distancesFrom(startIndex int) []int {
+	distances, _ := m.breadthFirstSearch(startIndex, nil)
+	return distances
+}
+
+// Performs a breadth-first search starting at the given cell. Returns the
+// number of moves required to reach each cell, and the index of the cell from
+// which each cell was reached. Both slices contain -1 for cells that can't be
+// reached, and the start cell's parent is also -1. If canMove is non-nil, any
+// move for which it returns false is skipped.
+func (m *GridMaze) breadthFirstSearch(startIndex int,
+	canMove func(from, to int) bool) ([]int, []int) {
+	distances := make([]int, len(m.cells))
+	parents := make([]int, len(m.cells))
+	for i := range distances {
+		distances[i] = -1
+		parents[i] = -1
+	}
+	distances[startIndex] = 0
+	queue := make([]int, 0, len(m.cells))
+	queue = append(queue, startIndex)
+	var neighbors []int
+	for len(queue) != 0 {
+		current := queue[0]
+		queue = queue[1:]
+		neighbors = m.openNeighbors(current, neighbors[:0])
+		for _, n := range neighbors {
+			if distances[n] >= 0 {
+				continue
+			}
+			if (canMove != nil) && !canMove(current, n) {
+				continue
+			}
+			distances[n] = distances[current] + 1
+			parents[n] = current
+			queue = append(queue, n)
+		}
+	}
+	return distances, parents
+}
+
+// Follows the chain of parent indices returned by breadthFirstSearch back
+// from endIndex, and returns the path from the search's start to endIndex.
+// Returns nil if endIndex wasn't reached. The distances may be any values
+// that are negative only for unreached cells, such as costs.
+func pathFromParents(distances, parents []int, endIndex int) []int {
+	if distances[endIndex] < 0 {
+		return nil
+	}
+	var path []int
+	for index := endIndex; index >= 0; index = parents[index] {
+		path = append(path, index)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Returns the indices of the cells on a shortest path between the two given
+// cells, including both of them. Returns nil if the end can't be reached from
+// the start.
+func (m *GridMaze) shortestPath(startIndex, endIndex int) []int {
+	distances, parents := m.breadthFirstSearch(startIndex, nil)
+	return pathFromParents(distances, parents, endIndex)
+}
+
+// Returns the distance, in moves through open passages, from the given cell
+// to every cell in the maze. The returned slice contains one entry per cell,
+// with the distance to the cell at column c and row r at index
+// r * m.CellsWide() + c. Unreachable cells have a distance of -1.
+func (m *GridMaze) DistancesFrom(cell image.Point) ([]int, error) {
+	index, e := m.cellIndex(cell)
+	if e != nil {
+		return nil, e
+	}
+	if m.cells[index].state.excluded() {
+		return nil, fmt.Errorf("Cell (%d, %d) is excluded from the maze",
+			cell.X, cell.Y)
+	}
+	return m.distancesFrom(index), nil
+}
+
+// A list of colors, evenly spaced between 0.0 and 1.0, used to map numbers to
+// colors.
+type Gradient []color.Color
+
+// Returns the gradient used for heat maps if no other gradient is specified.
+// Goes from blue (near) through green and yellow to red (far).
+func DefaultGradient() Gradient {
+	return Gradient{
+		color.RGBA{40, 60, 220, 255},
+		color.RGBA{40, 200, 80, 255},
+		color.RGBA{240, 220, 40, 255},
+		color.RGBA{220, 40, 30, 255},
+	}
+}
+
+// Returns the color at position t in the gradient, linearly interpolating
+// between the two nearest colors. Values of t are clamped to the range
+// [0.0, 1.0]. Returns black if the gradient is empty.
+func (g Gradient) At(t float64) color.Color {
+	if len(g) == 0 {
+		return color.Black
+	}
+	if (len(g) == 1) || (t <= 0) {
+		return g[0]
+	}
+	if t >= 1 {
+		return g[len(g)-1]
+	}
+	position := t * float64(len(g)-1)
+	index := int(position)
+	frac := position - float64(index)
+	r1, g1, b1, a1 := g[index].RGBA()
+	r2, g2, b2, a2 := g[index+1].RGBA()
+	mix := func(a, b uint32) uint16 {
+		return uint16(float64(a)*(1-frac) + float64(b)*frac)
+	}
+	return color.RGBA64{
+		R: mix(r1, r2),
+		G: mix(g1, g2),
+		B: mix(b1, b2),
+		A: mix(a1, a2),
+	}
+}
+
+// Returns an image of the maze in which every cell reachable from the given
+// cell is colored according to its distance from the cell: the cell itself
+// gets the first color in the gradient and the farthest cell gets the last.
+// Uses DefaultGradient() if the given gradient is empty. The solution is never
+// shown in the returned image.
+func (m *GridMaze) DistanceHeatMap(cell image.Point,
+	gradient Gradient) (image.Image, error) {
+	distances, e := m.DistancesFrom(cell)
+	if e != nil {
+		return nil, e
+	}
+	return m.heatMap(distances, gradient), nil
+}
+
+// Returns an image of the maze with each cell colored according to its value
+// in the given slice, relative to the largest value in the slice. Cells with
+// negative values aren't colored. Used by DistanceHeatMap.
+func (m *GridMaze) heatMap(values []int, gradient Gradient) image.Image {
+	if len(gradient) == 0 {
+		gradient = DefaultGradient()
+	}
+	maxValue := 0
+	for _, v := range values {
+		if v > maxValue {
+			maxValue = v
+		}
+	}
+	overlay := newCellOverlay(m.copyWithoutSolution())
+	for i, v := range values {
+		if v < 0 {
+			continue
+		}
+		t := 0.0
+		if maxValue > 0 {
+			t = float64(v) / float64(maxValue)
+		}
+		overlay.colors[i] = gradient.At(t)
+	}
+	return overlay
+}
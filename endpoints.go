@@ -0,0 +1,202 @@
+package maze
+
+// This file contains functions for choosing the start and end cells of a
+// GridMaze.
+
+import (
+	"fmt"
+)
+
+// Determines how a GridMaze chooses its start and end cells.
+type EndpointMode uint8
+
+const (
+	// Keep the start and end cells chosen when the maze was created: either
+	// the top-left and bottom-right corners, or random cells chosen among
+	// the candidates marked in the template.
+	EndpointsDefault EndpointMode = iota
+	// Use the two cells that are farthest apart in the maze. This is only
+	// guaranteed if the maze has no loops; otherwise the cells are chosen
+	// using a heuristic, and may not be the farthest apart.
+	EndpointsLongestPath
+	// Use the two cells that are farthest apart among cells that are on the
+	// maze's border or adjacent to an excluded cell, i.e. cells where an
+	// entrance or exit can be drawn. As with EndpointsLongestPath, this is
+	// only guaranteed if the maze has no loops.
+	EndpointsLongestBorderPath
+	// Use the start and end candidates marked in the template that are
+	// farthest apart. If the template has no start (or end) candidates, any
+	// cell may be used as the start (or end). Every start candidate is
+	// checked if there are at most maxExactStartCandidates of them;
+	// otherwise the candidates are chosen using a heuristic, and may not be
+	// the farthest apart.
+	EndpointsLongestTemplatePath
+)
+
+// The largest number of start candidates for which
+// EndpointsLongestTemplatePath finds the farthest pair of candidates by
+// checking the distance from every start candidate.
+const maxExactStartCandidates = 32
+
+func (e EndpointMode) String() string {
+	switch e {
+	case EndpointsDefault:
+		return "default"
+	case EndpointsLongestPath:
+		return "longest path"
+	case EndpointsLongestBorderPath:
+		return "longest border path"
+	case EndpointsLongestTemplatePath:
+		return "longest template path"
+	}
+	return fmt.Sprintf("Unknown EndpointMode: %d", uint8(e))
+}
+
+// Sets how the maze chooses its start and end cells. The mode is applied to
+// the maze's current layout immediately, and again every time the maze is
+// regenerated. Setting the mode back to EndpointsDefault leaves the current
+// start and end cells in place.
+func (m *GridMaze) SetEndpointMode(mode EndpointMode) error {
+	if mode > EndpointsLongestTemplatePath {
+		return fmt.Errorf("Invalid endpoint mode: %s", mode)
+	}
+	m.endpointMode = mode
+	return m.applyEndpointMode()
+}
+
+// Returns true if the cell at the given index is on the maze's border or next
+// to an excluded cell.
+func (m *GridMaze) isBorderCell(cellIndex int) bool {
+	for dir := 0; dir < 4; dir++ {
+		neighbor := m.neighborInDirection(cellIndex, dir)
+		if (neighbor < 0) || m.cells[neighbor].state.outside() {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a slice with one entry per cell that is true for every cell that
+// is not excluded and satisfies the given filter. The filter may be nil to
+// accept all non-excluded cells.
+func (m *GridMaze) candidateMask(filter func(cellIndex int) bool) []bool {
+	toReturn := make([]bool, len(m.cells))
+	for i := range m.cells {
+		if m.cells[i].state.excluded() {
+			continue
+		}
+		toReturn[i] = (filter == nil) || filter(i)
+	}
+	return toReturn
+}
+
+// Returns a mask containing only the given cell indices, or every
+// non-excluded cell if the list is empty.
+func (m *GridMaze) indexListMask(indices []int) []bool {
+	if len(indices) == 0 {
+		return m.candidateMask(nil)
+	}
+	toReturn := make([]bool, len(m.cells))
+	for _, index := range indices {
+		toReturn[index] = true
+	}
+	return toReturn
+}
+
+// Returns the index of the candidate cell farthest from the given cell.
+// Returns -1 if no candidate can be reached.
+func (m *GridMaze) farthestCandidate(fromIndex int, candidates []bool) int {
+	toReturn := -1
+	bestDistance := -1
+	for i, d := range m.distancesFrom(fromIndex) {
+		if candidates[i] && (d > bestDistance) {
+			toReturn = i
+			bestDistance = d
+		}
+	}
+	return toReturn
+}
+
+// Returns the indices of the start and end candidates that are farthest
+// apart, by checking the distance from every start candidate. Returns -1 for
+// both if no end candidate can be reached from any start candidate.
+func (m *GridMaze) farthestCandidatePair(starts []int,
+	endMask []bool) (int, int) {
+	bestStart, bestEnd := -1, -1
+	bestDistance := -1
+	for _, startIndex := range starts {
+		for i, d := range m.distancesFrom(startIndex) {
+			if endMask[i] && (d > bestDistance) {
+				bestStart, bestEnd = startIndex, i
+				bestDistance = d
+			}
+		}
+	}
+	return bestStart, bestEnd
+}
+
+// Returns the index of the first candidate cell, or -1 if there aren't any.
+func firstCandidate(candidates []bool) int {
+	for i, ok := range candidates {
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// Sets the start and end cells according to the maze's endpoint mode. Called
+// after each generation. Unless every start candidate can be checked, this
+// relies on a double breadth-first search: in a perfect maze, the candidate
+// farthest from any candidate is one end of the longest path between
+// candidates, and the candidate farthest from it is the other end. This isn't
+// guaranteed if the maze has loops, or if the start and end candidates
+// differ.
+func (m *GridMaze) applyEndpointMode() error {
+	var startMask, endMask []bool
+	switch m.endpointMode {
+	case EndpointsDefault:
+		return nil
+	case EndpointsLongestPath:
+		startMask = m.candidateMask(nil)
+		endMask = startMask
+	case EndpointsLongestBorderPath:
+		startMask = m.candidateMask(m.isBorderCell)
+		endMask = startMask
+	case EndpointsLongestTemplatePath:
+		startMask = m.indexListMask(m.startCandidates)
+		endMask = m.indexListMask(m.endCandidates)
+	default:
+		return fmt.Errorf("Invalid endpoint mode: %s", m.endpointMode)
+	}
+	startIndex, endIndex := -1, -1
+	if (m.endpointMode == EndpointsLongestTemplatePath) &&
+		(len(m.startCandidates) != 0) &&
+		(len(m.startCandidates) <= maxExactStartCandidates) {
+		startIndex, endIndex = m.farthestCandidatePair(m.startCandidates,
+			endMask)
+	} else {
+		initial := firstCandidate(startMask)
+		if initial < 0 {
+			return fmt.Errorf("No cells are eligible to be endpoints with "+
+				"mode %s", m.endpointMode)
+		}
+		// Alternate between the start and end candidates, so that the
+		// start is always a start candidate and the end is always an end
+		// candidate.
+		endIndex = m.farthestCandidate(initial, endMask)
+		if endIndex >= 0 {
+			startIndex = m.farthestCandidate(endIndex, startMask)
+			endIndex = m.farthestCandidate(startIndex, endMask)
+		}
+	}
+	if endIndex < 0 {
+		return fmt.Errorf("No end cell is reachable from the start "+
+			"candidates with mode %s", m.endpointMode)
+	}
+	m.startCellIndex = startIndex
+	m.endCellIndex = endIndex
+	m.startIndices = nil
+	m.endIndices = nil
+	return nil
+}
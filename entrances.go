@@ -0,0 +1,213 @@
+package maze
+
+// This file contains functions for mazes with multiple entrances and exits.
+
+import (
+	"fmt"
+	"image"
+)
+
+// Returns the indices of every start cell in the maze.
+func (m *GridMaze) allStarts() []int {
+	if len(m.startIndices) == 0 {
+		return []int{m.startCellIndex}
+	}
+	return m.startIndices
+}
+
+// Returns the indices of every end cell in the maze.
+func (m *GridMaze) allEnds() []int {
+	if len(m.endIndices) == 0 {
+		return []int{m.endCellIndex}
+	}
+	return m.endIndices
+}
+
+// Converts a list of cell coordinates to a list of indices of cells that are
+// part of the maze.
+func (m *GridMaze) endpointIndices(cells []image.Point) ([]int, error) {
+	if len(cells) == 0 {
+		return nil, fmt.Errorf("At least one cell is required")
+	}
+	toReturn := make([]int, len(cells))
+	for i, p := range cells {
+		index, e := m.cellIndex(p)
+		if e != nil {
+			return nil, e
+		}
+		if m.cells[index].state.excluded() {
+			return nil, fmt.Errorf("Cell (%d, %d) is excluded from the maze",
+				p.X, p.Y)
+		}
+		toReturn[i] = index
+	}
+	return toReturn, nil
+}
+
+// Sets the maze's entrances and exits. The first start and end are used by
+// ShowSolution and the StartPoint and EndPoint fields of MazeInfo. All of
+// them are listed in the Starts and Ends fields of MazeInfo. Overrides the
+// maze's endpoint mode until it is set again.
+func (m *GridMaze) SetEndpoints(starts, ends []image.Point) error {
+	startIndices, e := m.endpointIndices(starts)
+	if e != nil {
+		return fmt.Errorf("Invalid start cells: %w", e)
+	}
+	endIndices, e := m.endpointIndices(ends)
+	if e != nil {
+		return fmt.Errorf("Invalid end cells: %w", e)
+	}
+	m.endpointMode = EndpointsDefault
+	m.startIndices = startIndices
+	m.endIndices = endIndices
+	m.startCellIndex = startIndices[0]
+	m.endCellIndex = endIndices[0]
+	return nil
+}
+
+// Uses every start and end candidate marked in the maze's template as an
+// entrance or exit, rather than only one of each. Returns an error if the
+// template didn't mark any start or end candidates.
+func (m *GridMaze) UseAllEndpointCandidates() error {
+	if (len(m.startCandidates) == 0) || (len(m.endCandidates) == 0) {
+		return fmt.Errorf("The maze's template didn't mark both start and " +
+			"end candidates")
+	}
+	starts := make([]image.Point, len(m.startCandidates))
+	for i, index := range m.startCandidates {
+		starts[i] = m.cellPoint(index)
+	}
+	ends := make([]image.Point, len(m.endCandidates))
+	for i, index := range m.endCandidates {
+		ends[i] = m.cellPoint(index)
+	}
+	return m.SetEndpoints(starts, ends)
+}
+
+// Describes whether a path exists between one of the maze's starts and one of
+// its ends.
+type EndpointConnection struct {
+	Start image.Point
+	End   image.Point
+	// True if End can be reached from Start.
+	Connected bool
+	// The number of moves on the shortest path from Start to End. -1 if they
+	// aren't connected.
+	Length int
+}
+
+// Returns one entry for every combination of start and end cells, in the
+// order the starts and ends were given to SetEndpoints, describing which
+// pairs are connected.
+func (m *GridMaze) EndpointConnections() []EndpointConnection {
+	starts := m.allStarts()
+	ends := m.allEnds()
+	toReturn := make([]EndpointConnection, 0, len(starts)*len(ends))
+	for _, start := range starts {
+		distances := m.distancesFrom(start)
+		for _, end := range ends {
+			toReturn = append(toReturn, EndpointConnection{
+				Start:     m.cellPoint(start),
+				End:       m.cellPoint(end),
+				Connected: distances[end] >= 0,
+				Length:    distances[end],
+			})
+		}
+	}
+	return toReturn
+}
+
+// Returns the direction (0 = left, 1 = up, 2 = right, 3 = down) from the cell
+// at index a to the adjacent cell at index b, or -1 if they aren't adjacent.
+func (m *GridMaze) directionBetween(a, b int) int {
+	for dir := 0; dir < 4; dir++ {
+		if m.neighborInDirection(a, dir) == b {
+			return dir
+		}
+	}
+	return -1
+}
+
+// Returns the index of the end cell nearest to the given cell, along with the
+// distances from the cell to every other cell. The returned index is -1 if no
+// end can be reached.
+func (m *GridMaze) nearestEnd(cellIndex int) (int, []int) {
+	distances := m.distancesFrom(cellIndex)
+	toReturn := -1
+	for _, end := range m.allEnds() {
+		d := distances[end]
+		if (d >= 0) && ((toReturn < 0) || (d < distances[toReturn])) {
+			toReturn = end
+		}
+	}
+	return toReturn, distances
+}
+
+// Adds walls so that only the start at the given position in the list of
+// starts can reach any of the maze's ends, producing "which entrance leads to
+// the exit?" puzzles. Each other start is cut off where its route to an exit
+// would join the kept start's route, so the routes look plausible for as long
+// as possible. The kept start becomes the one used by ShowSolution, but the
+// order of starts in MazeInfo is unchanged.
+func (m *GridMaze) IsolateStarts(keep int) error {
+	starts := m.allStarts()
+	if (keep < 0) || (keep >= len(starts)) {
+		return fmt.Errorf("Invalid start index %d; the maze has %d start(s)",
+			keep, len(starts))
+	}
+	keptStart := starts[keep]
+	keptEnd, _ := m.nearestEnd(keptStart)
+	if keptEnd < 0 {
+		return fmt.Errorf("No end can be reached from the kept start")
+	}
+	// Walls are never added between two cells on the kept route, so it
+	// always remains open.
+	protected := make([]bool, len(m.cells))
+	for _, index := range m.shortestPath(keptStart, keptEnd) {
+		protected[index] = true
+	}
+	for i, start := range starts {
+		if i == keep {
+			continue
+		}
+		if protected[start] {
+			p := m.cellPoint(start)
+			return fmt.Errorf("Start (%d, %d) lies on the kept start's route",
+				p.X, p.Y)
+		}
+		// Mazes with loops may need several walls to cut a start off.
+		for attempts := 0; ; attempts++ {
+			end, _ := m.nearestEnd(start)
+			if end < 0 {
+				break
+			}
+			if attempts >= len(m.cells) {
+				return fmt.Errorf("Internal error: failed to isolate start")
+			}
+			path := m.shortestPath(start, end)
+			if len(path) < 2 {
+				p := m.cellPoint(start)
+				return fmt.Errorf("Start (%d, %d) is also an end", p.X, p.Y)
+			}
+			// Cut the path just before it joins the kept route, or just
+			// before the end if it never does.
+			cut := len(path) - 1
+			for j := 1; j < len(path); j++ {
+				if protected[path[j]] {
+					cut = j
+					break
+				}
+			}
+			dir := m.directionBetween(path[cut-1], path[cut])
+			if dir < 0 {
+				p := m.cellPoint(start)
+				return fmt.Errorf("Can't isolate start (%d, %d): its route "+
+					"joins the kept route through a portal", p.X, p.Y)
+			}
+			m.setWall(path[cut-1], dir, true)
+		}
+	}
+	m.startCellIndex = keptStart
+	m.endCellIndex = keptEnd
+	return nil
+}
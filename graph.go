@@ -0,0 +1,409 @@
+package maze
+
+// This file contains functions for exporting a GridMaze's passages as a graph,
+// for analysis with network tools.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Options controlling how a maze's graph is exported.
+type GraphExportOptions struct {
+	// If set, chains of cells with exactly two passages are collapsed into
+	// single weighted edges, so the only nodes are junctions, dead ends,
+	// endpoints, waypoints, and portals.
+	CollapseCorridors bool
+}
+
+// An edge in a maze's passage graph, connecting two nodes identified by cell
+// index.
+type graphEdge struct {
+	from, to int
+	// Every cell along the edge, starting with from and ending with to.
+	cells []int
+	// Whether the edge can be traversed from from to to, and from to to
+	// from. At least one is always true.
+	forward, backward bool
+	// Set if the edge is a single step through a portal.
+	portal bool
+}
+
+// Returns the number of moves needed to traverse the edge.
+func (e *graphEdge) weight() int {
+	return len(e.cells) - 1
+}
+
+// Identifies the role of a cell in the maze's graph.
+type JunctionKind uint8
+
+const (
+	// A cell with three or more passages.
+	JunctionFork JunctionKind = iota
+	// A cell with exactly one passage.
+	JunctionDeadEnd
+	// A cell with exactly two passages. When corridors are collapsed, these
+	// are only nodes when they're needed to represent a loop without any
+	// forks.
+	JunctionCorridor
+	// A cell with no passages at all.
+	JunctionIsolated
+	// One of the maze's start cells.
+	JunctionStart
+	// One of the maze's end cells.
+	JunctionEnd
+	// One of the maze's waypoints.
+	JunctionWaypoint
+	// A cell containing a portal.
+	JunctionPortal
+)
+
+func (k JunctionKind) String() string {
+	switch k {
+	case JunctionFork:
+		return "junction"
+	case JunctionDeadEnd:
+		return "deadEnd"
+	case JunctionCorridor:
+		return "corridor"
+	case JunctionIsolated:
+		return "isolated"
+	case JunctionStart:
+		return "start"
+	case JunctionEnd:
+		return "end"
+	case JunctionWaypoint:
+		return "waypoint"
+	case JunctionPortal:
+		return "portal"
+	}
+	return fmt.Sprintf("Unknown JunctionKind: %d", uint8(k))
+}
+
+// Returns the role of the cell in the maze's graph.
+func (m *GridMaze) junctionKind(cellIndex int) JunctionKind {
+	for _, index := range m.allStarts() {
+		if index == cellIndex {
+			return JunctionStart
+		}
+	}
+	for _, index := range m.allEnds() {
+		if index == cellIndex {
+			return JunctionEnd
+		}
+	}
+	for _, index := range m.waypoints {
+		if index == cellIndex {
+			return JunctionWaypoint
+		}
+	}
+	if m.portalPartner(cellIndex) >= 0 {
+		return JunctionPortal
+	}
+	switch len(m.graphNeighbors(cellIndex, nil)) {
+	case 0:
+		return JunctionIsolated
+	case 1:
+		return JunctionDeadEnd
+	case 2:
+		return JunctionCorridor
+	}
+	return JunctionFork
+}
+
+// Returns true if a single move from the cell at index a to the cell at
+// index b is allowed.
+func (m *GridMaze) canStep(a, b int) bool {
+	if m.portalPartner(a) == b {
+		return true
+	}
+	dir := m.directionBetween(a, b)
+	if dir < 0 {
+		return false
+	}
+	c := &(m.cells[a])
+	return !c.walls[dir] && !c.noExit[dir]
+}
+
+// Returns true if the cell must be a node in a graph with collapsed
+// corridors, even if it has exactly two neighbors.
+func (m *GridMaze) isGraphLandmark(cellIndex int, degree int) bool {
+	if degree != 2 {
+		return true
+	}
+	if m.portalPartner(cellIndex) >= 0 {
+		return true
+	}
+	for _, lists := range [][]int{m.allStarts(), m.allEnds(), m.waypoints} {
+		for _, index := range lists {
+			if index == cellIndex {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Builds the maze's passage graph. Returns the cell indices of the nodes, in
+// increasing order, and the edges. If collapse is set, corridors are
+// collapsed into single edges; see GraphExportOptions.
+func (m *GridMaze) passageGraph(collapse bool) ([]int, []graphEdge) {
+	isNode := make([]bool, len(m.cells))
+	var nodes []int
+	var neighbors []int
+	for i := range m.cells {
+		if m.cells[i].state.excluded() {
+			continue
+		}
+		neighbors = m.graphNeighbors(i, neighbors[:0])
+		if !collapse || m.isGraphLandmark(i, len(neighbors)) {
+			isNode[i] = true
+			nodes = append(nodes, i)
+		}
+	}
+
+	var edges []graphEdge
+	// Tracks the first step of every edge that's been followed, from either
+	// end, so that each edge is only added once. Each cell has an entry for
+	// each direction, followed by one for its portal.
+	used := make([]bool, len(m.cells)*5)
+	stepIndex := func(from, to int) int {
+		dir := m.directionBetween(from, to)
+		if dir < 0 {
+			dir = 4
+		}
+		return from*5 + dir
+	}
+	// Tracks the cells on every edge that's been followed, including edges
+	// that were dropped because they can't be traversed.
+	reached := make([]bool, len(m.cells))
+	var startNeighbors []int
+	followEdges := func(start int) {
+		startNeighbors = m.graphNeighbors(start, startNeighbors[:0])
+		for _, next := range startNeighbors {
+			if used[stepIndex(start, next)] {
+				continue
+			}
+			edge := graphEdge{
+				cells:    []int{start, next},
+				forward:  m.canStep(start, next),
+				backward: m.canStep(next, start),
+				portal:   m.portalPartner(start) == next,
+			}
+			previous, current := start, next
+			for !isNode[current] {
+				neighbors = m.graphNeighbors(current, neighbors[:0])
+				following := neighbors[0]
+				if following == previous {
+					following = neighbors[1]
+				}
+				edge.forward = edge.forward && m.canStep(current, following)
+				edge.backward = edge.backward &&
+					m.canStep(following, current)
+				previous, current = current, following
+				edge.cells = append(edge.cells, current)
+			}
+			used[stepIndex(start, next)] = true
+			used[stepIndex(current, previous)] = true
+			for _, index := range edge.cells {
+				reached[index] = true
+			}
+			// Corridors with one-way passages pointing in opposite
+			// directions can't be traversed at all.
+			if !edge.forward && !edge.backward {
+				continue
+			}
+			edge.from, edge.to = start, current
+			edges = append(edges, edge)
+		}
+	}
+	for _, node := range nodes {
+		followEdges(node)
+	}
+	// Any cells that weren't reached form loops without any junctions, so
+	// make one cell in each loop a node.
+	if collapse {
+		for i := range m.cells {
+			if reached[i] || isNode[i] || m.cells[i].state.excluded() {
+				continue
+			}
+			isNode[i] = true
+			nodes = append(nodes, i)
+			followEdges(i)
+		}
+	}
+	return nodes, edges
+}
+
+// Returns the ID used for the cell's node in exported graphs.
+func (m *GridMaze) graphNodeID(cellIndex int) string {
+	p := m.cellPoint(cellIndex)
+	return fmt.Sprintf("c%d_%d", p.X, p.Y)
+}
+
+// Writes the maze's passage graph in Graphviz DOT format. Each node has its
+// cell's column and row as a position, and each edge's weight is the number
+// of moves needed to traverse it. One-way edges have dir=forward or
+// dir=back. If opts is nil, corridors aren't collapsed.
+func (m *GridMaze) WriteDOT(w io.Writer, opts *GraphExportOptions) error {
+	if opts == nil {
+		opts = &GraphExportOptions{}
+	}
+	nodes, edges := m.passageGraph(opts.CollapseCorridors)
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "graph maze {\n")
+	for _, index := range nodes {
+		p := m.cellPoint(index)
+		fmt.Fprintf(out, "  %s [label=\"%d,%d\", pos=\"%d,%d!\", "+
+			"kind=\"%s\"];\n", m.graphNodeID(index), p.X, p.Y, p.X, -p.Y,
+			m.junctionKind(index))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(out, "  %s -- %s [weight=%d, label=\"%d\"",
+			m.graphNodeID(e.from), m.graphNodeID(e.to), e.weight(),
+			e.weight())
+		if !e.backward {
+			fmt.Fprintf(out, ", dir=forward")
+		} else if !e.forward {
+			fmt.Fprintf(out, ", dir=back")
+		}
+		if e.portal {
+			fmt.Fprintf(out, ", style=dashed")
+		}
+		fmt.Fprintf(out, "];\n")
+	}
+	fmt.Fprintf(out, "}\n")
+	e := out.Flush()
+	if e != nil {
+		return fmt.Errorf("Error writing DOT graph: %w", e)
+	}
+	return nil
+}
+
+// Writes the maze's passage graph in GraphML format. Nodes have "x", "y",
+// and "kind" attributes, and edges have "weight" and "portal" attributes.
+// One-way edges are marked as directed. If opts is nil, corridors aren't
+// collapsed.
+func (m *GridMaze) WriteGraphML(w io.Writer, opts *GraphExportOptions) error {
+	if opts == nil {
+		opts = &GraphExportOptions{}
+	}
+	nodes, edges := m.passageGraph(opts.CollapseCorridors)
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(out, "<graphml xmlns=\"http://graphml.graphdrawing.org/"+
+		"xmlns\">\n")
+	fmt.Fprintf(out, "  <key id=\"x\" for=\"node\" attr.name=\"x\" "+
+		"attr.type=\"int\"/>\n")
+	fmt.Fprintf(out, "  <key id=\"y\" for=\"node\" attr.name=\"y\" "+
+		"attr.type=\"int\"/>\n")
+	fmt.Fprintf(out, "  <key id=\"kind\" for=\"node\" attr.name=\"kind\" "+
+		"attr.type=\"string\"/>\n")
+	fmt.Fprintf(out, "  <key id=\"weight\" for=\"edge\" "+
+		"attr.name=\"weight\" attr.type=\"int\"/>\n")
+	fmt.Fprintf(out, "  <key id=\"portal\" for=\"edge\" "+
+		"attr.name=\"portal\" attr.type=\"boolean\"/>\n")
+	fmt.Fprintf(out, "  <graph id=\"maze\" edgedefault=\"undirected\">\n")
+	for _, index := range nodes {
+		p := m.cellPoint(index)
+		fmt.Fprintf(out, "    <node id=\"%s\">\n", m.graphNodeID(index))
+		fmt.Fprintf(out, "      <data key=\"x\">%d</data>\n", p.X)
+		fmt.Fprintf(out, "      <data key=\"y\">%d</data>\n", p.Y)
+		fmt.Fprintf(out, "      <data key=\"kind\">%s</data>\n",
+			m.junctionKind(index))
+		fmt.Fprintf(out, "    </node>\n")
+	}
+	for _, e := range edges {
+		source, target := e.from, e.to
+		directed := ""
+		if !e.forward {
+			source, target = e.to, e.from
+		}
+		if !e.forward || !e.backward {
+			directed = " directed=\"true\""
+		}
+		fmt.Fprintf(out, "    <edge source=\"%s\" target=\"%s\"%s>\n",
+			m.graphNodeID(source), m.graphNodeID(target), directed)
+		fmt.Fprintf(out, "      <data key=\"weight\">%d</data>\n",
+			e.weight())
+		fmt.Fprintf(out, "      <data key=\"portal\">%t</data>\n", e.portal)
+		fmt.Fprintf(out, "    </edge>\n")
+	}
+	fmt.Fprintf(out, "  </graph>\n")
+	fmt.Fprintf(out, "</graphml>\n")
+	e := out.Flush()
+	if e != nil {
+		return fmt.Errorf("Error writing GraphML: %w", e)
+	}
+	return nil
+}
+
+// A single neighbor in the JSON adjacency list.
+type jsonNeighbor struct {
+	ID     string `json:"id"`
+	Weight int    `json:"weight"`
+	Portal bool   `json:"portal,omitempty"`
+}
+
+// A single node in the JSON adjacency list.
+type jsonNode struct {
+	ID        string         `json:"id"`
+	X         int            `json:"x"`
+	Y         int            `json:"y"`
+	Kind      string         `json:"kind"`
+	Neighbors []jsonNeighbor `json:"neighbors"`
+}
+
+// Writes the maze's passage graph as a JSON adjacency list: an object with a
+// "nodes" array, where each node lists the nodes that can be reached from it
+// in its "neighbors" array, along with the number of moves required. One-way
+// edges only appear in the neighbors of the node they can be traversed from.
+// If opts is nil, corridors aren't collapsed.
+func (m *GridMaze) WriteAdjacencyJSON(w io.Writer,
+	opts *GraphExportOptions) error {
+	if opts == nil {
+		opts = &GraphExportOptions{}
+	}
+	nodes, edges := m.passageGraph(opts.CollapseCorridors)
+	nodeIndices := make(map[int]int, len(nodes))
+	jsonNodes := make([]jsonNode, len(nodes))
+	for i, index := range nodes {
+		p := m.cellPoint(index)
+		nodeIndices[index] = i
+		jsonNodes[i] = jsonNode{
+			ID:        m.graphNodeID(index),
+			X:         p.X,
+			Y:         p.Y,
+			Kind:      m.junctionKind(index).String(),
+			Neighbors: []jsonNeighbor{},
+		}
+	}
+	addNeighbor := func(from, to int, e *graphEdge) {
+		n := &(jsonNodes[nodeIndices[from]])
+		n.Neighbors = append(n.Neighbors, jsonNeighbor{
+			ID:     m.graphNodeID(to),
+			Weight: e.weight(),
+			Portal: e.portal,
+		})
+	}
+	for i := range edges {
+		e := &(edges[i])
+		if e.forward {
+			addNeighbor(e.from, e.to, e)
+		}
+		if e.backward {
+			addNeighbor(e.to, e.from, e)
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	e := encoder.Encode(struct {
+		Nodes []jsonNode `json:"nodes"`
+	}{jsonNodes})
+	if e != nil {
+		return fmt.Errorf("Error writing JSON adjacency list: %w", e)
+	}
+	return nil
+}
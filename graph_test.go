@@ -0,0 +1,286 @@
+package maze
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"strings"
+	"testing"
+)
+
+// Exports the maze's graph as a JSON adjacency list and parses the result.
+func parseAdjacencyJSON(t *testing.T, m *GridMaze, collapse bool) []jsonNode {
+	var b bytes.Buffer
+	e := m.WriteAdjacencyJSON(&b, &GraphExportOptions{
+		CollapseCorridors: collapse,
+	})
+	if e != nil {
+		t.Fatalf("Failed writing JSON adjacency list: %s", e)
+	}
+	var parsed struct {
+		Nodes []jsonNode `json:"nodes"`
+	}
+	e = json.Unmarshal(b.Bytes(), &parsed)
+	if e != nil {
+		t.Fatalf("Failed parsing JSON adjacency list: %s", e)
+	}
+	return parsed.Nodes
+}
+
+// Returns the shortest distance between every pair of nodes in the parsed
+// adjacency list, keyed by node ID. Unreachable pairs are omitted.
+func adjacencyDistances(t *testing.T,
+	nodes []jsonNode) map[string]map[string]int {
+	ids := make(map[string]int, len(nodes))
+	for i, n := range nodes {
+		ids[n.ID] = i
+	}
+	distances := make([][]int, len(nodes))
+	for i, n := range nodes {
+		distances[i] = make([]int, len(nodes))
+		for j := range distances[i] {
+			distances[i][j] = -1
+		}
+		distances[i][i] = 0
+		for _, neighbor := range n.Neighbors {
+			j, ok := ids[neighbor.ID]
+			if !ok {
+				t.Fatalf("Node %s has unknown neighbor %s", n.ID, neighbor.ID)
+			}
+			if (distances[i][j] < 0) || (neighbor.Weight < distances[i][j]) {
+				distances[i][j] = neighbor.Weight
+			}
+		}
+	}
+	for k := range nodes {
+		for i := range nodes {
+			if distances[i][k] < 0 {
+				continue
+			}
+			for j := range nodes {
+				if distances[k][j] < 0 {
+					continue
+				}
+				d := distances[i][k] + distances[k][j]
+				if (distances[i][j] < 0) || (d < distances[i][j]) {
+					distances[i][j] = d
+				}
+			}
+		}
+	}
+	toReturn := make(map[string]map[string]int, len(nodes))
+	for i := range nodes {
+		toReturn[nodes[i].ID] = make(map[string]int)
+		for j := range nodes {
+			if distances[i][j] >= 0 {
+				toReturn[nodes[i].ID][nodes[j].ID] = distances[i][j]
+			}
+		}
+	}
+	return toReturn
+}
+
+// Returns the sum of the weights of every edge in the maze's passage graph.
+func totalEdgeWeight(m *GridMaze, collapse bool) int {
+	_, edges := m.passageGraph(collapse)
+	toReturn := 0
+	for i := range edges {
+		toReturn += edges[i].weight()
+	}
+	return toReturn
+}
+
+// Fails the test unless the collapsed and uncollapsed graphs agree on the
+// distance between every pair of nodes in the collapsed graph.
+func checkCollapsedDistances(t *testing.T, m *GridMaze) {
+	collapsedNodes := parseAdjacencyJSON(t, m, true)
+	collapsed := adjacencyDistances(t, collapsedNodes)
+	full := adjacencyDistances(t, parseAdjacencyJSON(t, m, false))
+	for _, a := range collapsedNodes {
+		for _, b := range collapsedNodes {
+			d, ok := collapsed[a.ID][b.ID]
+			expected, expectedOK := full[a.ID][b.ID]
+			if (ok != expectedOK) || (d != expected) {
+				t.Fatalf("Collapsed distance from %s to %s is %d (reachable "+
+					"= %t), expected %d (reachable = %t)", a.ID, b.ID, d, ok,
+					expected, expectedOK)
+			}
+		}
+	}
+}
+
+func TestAdjacencyJSONLoop(t *testing.T) {
+	// A corridor from the start to the end, a separate ring of four cells,
+	// and a separate pair of cells.
+	m := buildMaze(t, 4, 3, [][2]image.Point{
+		{{0, 0}, {0, 1}},
+		{{0, 1}, {0, 2}},
+		{{0, 2}, {1, 2}},
+		{{1, 2}, {2, 2}},
+		{{2, 2}, {3, 2}},
+		{{1, 0}, {2, 0}},
+		{{2, 0}, {2, 1}},
+		{{2, 1}, {1, 1}},
+		{{1, 1}, {1, 0}},
+		{{3, 0}, {3, 1}},
+	})
+	nodes := parseAdjacencyJSON(t, m, true)
+	kinds := make(map[string]string)
+	for _, n := range nodes {
+		kinds[n.ID] = n.Kind
+	}
+	expected := map[string]string{
+		"c0_0": "start",
+		"c3_2": "end",
+		"c1_0": "corridor",
+		"c3_0": "deadEnd",
+		"c3_1": "deadEnd",
+	}
+	if len(kinds) != len(expected) {
+		t.Fatalf("Got nodes %v, expected %v", kinds, expected)
+	}
+	for id, kind := range expected {
+		if kinds[id] != kind {
+			t.Fatalf("Node %s has kind %q, expected %q", id, kinds[id], kind)
+		}
+	}
+	if totalEdgeWeight(m, true) != 10 {
+		t.Fatalf("Collapsed edges have a total weight of %d, expected 10",
+			totalEdgeWeight(m, true))
+	}
+	checkCollapsedDistances(t, m)
+}
+
+func TestAdjacencyJSONOneWay(t *testing.T) {
+	// A corridor from the start to the end, which can't be traversed in
+	// either direction because its one-way passages point toward each other.
+	m := buildMaze(t, 5, 1, [][2]image.Point{
+		{{0, 0}, {1, 0}},
+		{{1, 0}, {2, 0}},
+		{{2, 0}, {3, 0}},
+		{{3, 0}, {4, 0}},
+	})
+	if (m.SetOneWay(image.Pt(1, 0), 2) != nil) ||
+		(m.SetOneWay(image.Pt(3, 0), 0) != nil) {
+		t.Fatalf("Failed setting one-way passages")
+	}
+	nodes := parseAdjacencyJSON(t, m, true)
+	if len(nodes) != 2 {
+		t.Fatalf("Got %d nodes, expected only the start and end", len(nodes))
+	}
+	for _, n := range nodes {
+		if len(n.Neighbors) != 0 {
+			t.Fatalf("Node %s has neighbors %v, expected none", n.ID,
+				n.Neighbors)
+		}
+	}
+	// The uncollapsed graph keeps every passage, in each direction it can be
+	// traversed.
+	moves := 0
+	for _, n := range parseAdjacencyJSON(t, m, false) {
+		moves += len(n.Neighbors)
+	}
+	if moves != 6 {
+		t.Fatalf("The uncollapsed graph has %d moves, expected 6", moves)
+	}
+	if totalEdgeWeight(m, false) != 4 {
+		t.Fatalf("Uncollapsed edges have a total weight of %d, expected 4",
+			totalEdgeWeight(m, false))
+	}
+	checkCollapsedDistances(t, m)
+}
+
+func TestAdjacencyJSONPortal(t *testing.T) {
+	// A straight corridor, with a portal linking its two ends.
+	m := buildMaze(t, 5, 1, [][2]image.Point{
+		{{0, 0}, {1, 0}},
+		{{1, 0}, {2, 0}},
+		{{2, 0}, {3, 0}},
+		{{3, 0}, {4, 0}},
+	})
+	e := m.AddPortal(image.Pt(0, 0), image.Pt(4, 0))
+	if e != nil {
+		t.Fatalf("Failed adding portal: %s", e)
+	}
+	nodes := parseAdjacencyJSON(t, m, true)
+	if (len(nodes) != 2) || (nodes[0].ID != "c0_0") ||
+		(len(nodes[0].Neighbors) != 2) {
+		t.Fatalf("Got nodes %v, expected the start with two neighbors", nodes)
+	}
+	portals := 0
+	for _, n := range nodes[0].Neighbors {
+		expected := 4
+		if n.Portal {
+			expected = 1
+			portals++
+		}
+		if (n.ID != "c4_0") || (n.Weight != expected) {
+			t.Fatalf("Got neighbor %v, expected c4_0 with weight %d", n,
+				expected)
+		}
+	}
+	if portals != 1 {
+		t.Fatalf("Got %d portal edges, expected 1", portals)
+	}
+	checkCollapsedDistances(t, m)
+}
+
+func TestGraphExports(t *testing.T) {
+	tests := []struct {
+		name    string
+		erosion int
+		oneWay  int
+		portals int
+	}{
+		{"perfect", 0, 0, 0},
+		{"braided", 3, 0, 0},
+		{"one-way", 3, 40, 0},
+		{"portal", 3, 0, 5},
+	}
+	for _, test := range tests {
+		for seed := int64(1); seed <= 5; seed++ {
+			m := newTestMaze(t, 10, 10, seed, test.erosion)
+			if test.oneWay > 0 {
+				_, e := m.AddOneWayPassages(test.oneWay, seed)
+				if e != nil {
+					t.Fatalf("Failed adding one-way passages: %s", e)
+				}
+			}
+			if test.portals > 0 {
+				_, e := m.PlacePortals(test.portals, seed)
+				if e != nil {
+					t.Fatalf("Failed placing portals: %s", e)
+				}
+			}
+			// Every passage and portal belongs to exactly one edge. When
+			// corridors are collapsed, one-way passages may make some of
+			// them impassable, in which case they're dropped.
+			expected := countPassages(m) + len(m.Portals())
+			for _, collapse := range []bool{false, true} {
+				if collapse && (test.oneWay > 0) {
+					continue
+				}
+				if totalEdgeWeight(m, collapse) != expected {
+					t.Fatalf("%s maze, seed %d: edges have a total weight of "+
+						"%d with collapse = %t, expected %d", test.name, seed,
+						totalEdgeWeight(m, collapse), collapse, expected)
+				}
+			}
+			checkCollapsedDistances(t, m)
+			var b bytes.Buffer
+			e := m.WriteDOT(&b, nil)
+			if (e != nil) || !strings.HasPrefix(b.String(), "graph maze {") {
+				t.Fatalf("%s maze, seed %d: failed writing DOT: %v",
+					test.name, seed, e)
+			}
+			b.Reset()
+			e = m.WriteGraphML(&b, &GraphExportOptions{
+				CollapseCorridors: true,
+			})
+			if (e != nil) || !strings.Contains(b.String(), "<graphml") {
+				t.Fatalf("%s maze, seed %d: failed writing GraphML: %v",
+					test.name, seed, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package maze
+
+// This file contains helpers shared by the package's tests.
+
+import (
+	"image"
+	"testing"
+)
+
+// Generates a maze with the given size and seed, then erodes its walls the
+// given number of times to add loops.
+func newTestMaze(t *testing.T, width, height int, seed int64,
+	erosion int) *GridMaze {
+	m, e := NewGridMazeWithSeed(width, height, seed)
+	if e != nil {
+		t.Fatalf("Failed generating %dx%d maze: %s", width, height, e)
+	}
+	for i := 0; i < erosion; i++ {
+		e = m.ErodeWalls()
+		if e != nil {
+			t.Fatalf("Failed eroding maze walls: %s", e)
+		}
+	}
+	return m
+}
+
+// Returns a maze of the given size in which the only open passages are
+// between each listed pair of adjacent cells. The start and end are the
+// top-left and bottom-right cells, as usual.
+func buildMaze(t *testing.T, width, height int,
+	passages [][2]image.Point) *GridMaze {
+	m := newTestMaze(t, width, height, 1, 0)
+	for i := range m.cells {
+		for dir := 2; dir <= 3; dir++ {
+			if m.neighborInDirection(i, dir) >= 0 {
+				m.setWall(i, dir, true)
+			}
+		}
+	}
+	for _, p := range passages {
+		a, e := m.cellIndex(p[0])
+		if e != nil {
+			t.Fatalf("Bad passage cell: %s", e)
+		}
+		b, e := m.cellIndex(p[1])
+		if e != nil {
+			t.Fatalf("Bad passage cell: %s", e)
+		}
+		dir := m.directionBetween(a, b)
+		if dir < 0 {
+			t.Fatalf("Cells %s and %s aren't adjacent", p[0], p[1])
+		}
+		m.setWall(a, dir, false)
+	}
+	return m
+}
+
+// Returns true if the cell at index b can be reached from the cell at index a
+// in a single move.
+func isLegalMove(m *GridMaze, a, b int) bool {
+	for _, n := range m.openNeighbors(a, nil) {
+		if n == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Fails the test unless the walk starts and ends at the given cells, and
+// each step in it is a single legal move.
+func checkWalk(t *testing.T, m *GridMaze, walk []image.Point, start,
+	end image.Point) {
+	if len(walk) == 0 {
+		t.Fatalf("Got an empty walk")
+	}
+	if walk[0] != start {
+		t.Fatalf("Walk starts at %s, expected %s", walk[0], start)
+	}
+	if walk[len(walk)-1] != end {
+		t.Fatalf("Walk ends at %s, expected %s", walk[len(walk)-1], end)
+	}
+	for i := 1; i < len(walk); i++ {
+		a, e := m.cellIndex(walk[i-1])
+		if e != nil {
+			t.Fatalf("Bad cell in walk: %s", e)
+		}
+		b, e := m.cellIndex(walk[i])
+		if e != nil {
+			t.Fatalf("Bad cell in walk: %s", e)
+		}
+		if !isLegalMove(m, a, b) {
+			t.Fatalf("Step %d of walk, from %s to %s, isn't a legal move",
+				i, walk[i-1], walk[i])
+		}
+	}
+}
+
+// Returns true if the route from the start, through each waypoint, to the
+// end can be followed. Computed independently of routeReachable, by
+// repeatedly relaxing reachability over every cell.
+func bruteForceRouteReachable(m *GridMaze) bool {
+	stops := m.routeStops()
+	for i := 1; i < len(stops); i++ {
+		reached := make([]bool, len(m.cells))
+		reached[stops[i-1]] = true
+		var neighbors []int
+		for changed := true; changed; {
+			changed = false
+			for j := range m.cells {
+				if !reached[j] {
+					continue
+				}
+				neighbors = m.openNeighbors(j, neighbors[:0])
+				for _, n := range neighbors {
+					if !reached[n] {
+						reached[n] = true
+						changed = true
+					}
+				}
+			}
+		}
+		if !reached[stops[i]] {
+			return false
+		}
+	}
+	return true
+}
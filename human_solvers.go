@@ -0,0 +1,371 @@
+package maze
+
+// This file contains solvers that imitate the methods a person might use to
+// solve a maze from the inside, only ever seeing the cell they're standing in.
+
+import (
+	"fmt"
+	"image"
+)
+
+// Identifies one of the human-style solving methods supported by
+// SolveLikeHuman.
+type HumanSolver uint8
+
+const (
+	// Keeps the left hand on the wall at all times.
+	SolverLeftHand HumanSolver = iota
+	// Keeps the right hand on the wall at all times.
+	SolverRightHand
+	// The Pledge algorithm: heads in a fixed direction, and follows walls
+	// (with the left hand) only until the total turning is back to zero.
+	SolverPledge
+	// Trémaux's algorithm: marks each passage every time it's walked, and
+	// never walks a passage more than twice.
+	SolverTremaux
+	// Repeatedly fills in dead ends until only the solution remains, then
+	// walks it.
+	SolverDeadEndFilling
+)
+
+func (s HumanSolver) String() string {
+	switch s {
+	case SolverLeftHand:
+		return "leftHand"
+	case SolverRightHand:
+		return "rightHand"
+	case SolverPledge:
+		return "pledge"
+	case SolverTremaux:
+		return "tremaux"
+	case SolverDeadEndFilling:
+		return "deadEndFilling"
+	}
+	return fmt.Sprintf("Unknown HumanSolver: %d", uint8(s))
+}
+
+// The result of solving a maze using SolveLikeHuman.
+type HumanSolution struct {
+	// The method used to solve the maze.
+	Solver HumanSolver
+	// Every cell visited, in order, starting at the start. Includes any
+	// backtracking, so cells may appear more than once.
+	Walk []image.Point
+	// The cells filled in by dead-end filling, in the order they were
+	// filled. Empty for the other solvers.
+	Filled []image.Point
+	// True if the walk reached the end. False if the solver got stuck or
+	// started going around in circles.
+	Solved bool
+}
+
+// Converts the solution to a SolverTrace, so it can be animated using
+// SolverTrace.Animation. Each step of the walk is a visited event, and each
+// filled cell is a dead-end event. The trace's path is the walk, if it
+// reached the end.
+func (s *HumanSolution) Trace() *SolverTrace {
+	toReturn := &SolverTrace{}
+	for _, p := range s.Filled {
+		toReturn.Events = append(toReturn.Events, SearchEvent{
+			Cell: p,
+			Kind: SearchDeadEnd,
+		})
+	}
+	for _, p := range s.Walk {
+		toReturn.Events = append(toReturn.Events, SearchEvent{
+			Cell: p,
+			Kind: SearchVisited,
+		})
+	}
+	if s.Solved {
+		toReturn.Path = append([]image.Point(nil), s.Walk...)
+	}
+	return toReturn
+}
+
+// Returns the index of the cell reached by stepping from the given cell in
+// the given direction, or -1 if a person couldn't take the step. Portals are
+// ignored, since a person following walls wouldn't notice them.
+func (m *GridMaze) humanStep(cellIndex, direction int) int {
+	c := &(m.cells[cellIndex])
+	if c.walls[direction] || c.noExit[direction] {
+		return -1
+	}
+	neighbor := m.neighborInDirection(cellIndex, direction)
+	if (neighbor < 0) || m.cells[neighbor].state.excluded() {
+		return -1
+	}
+	return neighbor
+}
+
+// Solves the maze from the start to the end using the given method, returning
+// every step taken. Waypoints and portals are ignored. Only returns an error
+// if the solver is invalid; check the returned Solved field to see if the
+// method actually worked.
+func (m *GridMaze) SolveLikeHuman(solver HumanSolver) (*HumanSolution,
+	error) {
+	var walk []int
+	var filled []int
+	solved := false
+	switch solver {
+	case SolverLeftHand:
+		walk, solved = m.followWall(false)
+	case SolverRightHand:
+		walk, solved = m.followWall(true)
+	case SolverPledge:
+		walk, solved = m.solvePledge()
+	case SolverTremaux:
+		walk, solved = m.solveTremaux()
+	case SolverDeadEndFilling:
+		walk, filled, solved = m.fillDeadEnds()
+	default:
+		return nil, fmt.Errorf("Invalid solver: %s", solver)
+	}
+	toReturn := &HumanSolution{
+		Solver: solver,
+		Walk:   make([]image.Point, len(walk)),
+		Filled: make([]image.Point, len(filled)),
+		Solved: solved,
+	}
+	for i, index := range walk {
+		toReturn.Walk[i] = m.cellPoint(index)
+	}
+	for i, index := range filled {
+		toReturn.Filled[i] = m.cellPoint(index)
+	}
+	return toReturn, nil
+}
+
+// Returns true if either the left-hand or the right-hand rule fails, i.e.
+// keeping that hand on the wall never reaches the end. Returns false only if
+// both succeed. Wall following fails when the end isn't connected to the
+// start's wall, for example when the end is in the interior of a maze
+// with loops, or is surrounded by excluded cells.
+func (m *GridMaze) WallFollowingFails() bool {
+	_, leftSolved := m.followWall(false)
+	_, rightSolved := m.followWall(true)
+	return !(leftSolved && rightSolved)
+}
+
+// Returns the order to try directions in when following a wall while facing
+// the given heading. Directions are numbered clockwise starting from the
+// left, so turning right adds 1 and turning left adds 3.
+func wallFollowingOrder(heading int, rightHand bool) [4]int {
+	if rightHand {
+		return [4]int{(heading + 1) % 4, heading, (heading + 3) % 4,
+			(heading + 2) % 4}
+	}
+	return [4]int{(heading + 3) % 4, heading, (heading + 1) % 4,
+		(heading + 2) % 4}
+}
+
+// Walks from the start keeping one hand on the wall. Returns the walk, and
+// whether it reached the end. Gives up as soon as the walker is in the same
+// cell, facing the same way, as at some earlier point.
+func (m *GridMaze) followWall(rightHand bool) ([]int, bool) {
+	seen := make([]bool, len(m.cells)*4)
+	current := m.startCellIndex
+	walk := []int{current}
+	// Start facing the first open direction, as if the walker had just
+	// entered through the opposite side.
+	heading := 1
+	for dir := 0; dir < 4; dir++ {
+		if m.humanStep(current, dir) >= 0 {
+			heading = dir
+			break
+		}
+	}
+	for current != m.endCellIndex {
+		if seen[current*4+heading] {
+			return walk, false
+		}
+		seen[current*4+heading] = true
+		next := -1
+		for _, dir := range wallFollowingOrder(heading, rightHand) {
+			next = m.humanStep(current, dir)
+			if next >= 0 {
+				heading = dir
+				break
+			}
+		}
+		if next < 0 {
+			return walk, false
+		}
+		current = next
+		walk = append(walk, current)
+	}
+	return walk, true
+}
+
+// Walks from the start using the Pledge algorithm, with the main direction
+// chosen to point towards the end. Returns the walk, and whether it reached
+// the end. Gives up after a number of steps proportional to the maze's size.
+func (m *GridMaze) solvePledge() ([]int, bool) {
+	start := m.cellPoint(m.startCellIndex)
+	end := m.cellPoint(m.endCellIndex)
+	var ranking [4]int
+	setDirRanking(start.X, start.Y, end.X, end.Y, ranking[:])
+	mainDir := ranking[0]
+	current := m.startCellIndex
+	walk := []int{current}
+	// The total number of right turns made while following a wall, minus
+	// the number of left turns.
+	turns := 0
+	heading := mainDir
+	following := false
+	limit := 16 * len(m.cells)
+	for steps := 0; current != m.endCellIndex; steps++ {
+		if steps >= limit {
+			return walk, false
+		}
+		next := -1
+		if !following {
+			next = m.humanStep(current, mainDir)
+			if next < 0 {
+				// Turn right until the way ahead is clear, keeping the
+				// obstacle on the left.
+				following = true
+				for i := 0; (i < 4) && (next < 0); i++ {
+					heading = (heading + 1) % 4
+					turns++
+					next = m.humanStep(current, heading)
+				}
+			}
+		} else {
+			order := wallFollowingOrder(heading, false)
+			turnAmounts := [4]int{-1, 0, 1, 2}
+			for i, dir := range order {
+				next = m.humanStep(current, dir)
+				if next >= 0 {
+					heading = dir
+					turns += turnAmounts[i]
+					break
+				}
+			}
+		}
+		if next < 0 {
+			return walk, false
+		}
+		current = next
+		walk = append(walk, current)
+		if following && (turns == 0) {
+			following = false
+		}
+	}
+	return walk, true
+}
+
+// Walks from the start using Trémaux's algorithm. Returns the walk, and
+// whether it reached the end.
+func (m *GridMaze) solveTremaux() ([]int, bool) {
+	// The number of times each passage has been walked, by passage ID.
+	marks := make(map[int]int)
+	visited := make([]bool, len(m.cells))
+	end := m.cellPoint(m.endCellIndex)
+	current := m.startCellIndex
+	visited[current] = true
+	walk := []int{current}
+	// The direction of the passage used to enter the current cell, or -1.
+	entrance := -1
+	// Whether the current cell had already been visited before the most
+	// recent step.
+	revisited := false
+	var ranking [4]int
+	for current != m.endCellIndex {
+		next, nextDir := -1, -1
+		// Walking into a cell that was already visited, along a new
+		// passage, means there's a loop, so turn back.
+		if revisited && (marks[m.passageID(current, entrance)] == 1) {
+			next = m.humanStep(current, entrance)
+			nextDir = entrance
+		}
+		if next < 0 {
+			// Otherwise, take an unwalked passage, preferring ones leading
+			// towards the end. If every passage has been walked, go back
+			// the way we came, or failing that, take any passage walked
+			// only once. Passages walked twice are never used again.
+			p := m.cellPoint(current)
+			setDirRanking(p.X, p.Y, end.X, end.Y, ranking[:])
+			fewest := 2
+			for _, dir := range ranking {
+				step := m.humanStep(current, dir)
+				if step < 0 {
+					continue
+				}
+				count := marks[m.passageID(current, dir)]
+				if count < fewest {
+					next, nextDir = step, dir
+					fewest = count
+				}
+			}
+			if (fewest == 1) && (entrance >= 0) &&
+				(marks[m.passageID(current, entrance)] == 1) {
+				step := m.humanStep(current, entrance)
+				if step >= 0 {
+					next, nextDir = step, entrance
+				}
+			}
+		}
+		if next < 0 {
+			return walk, false
+		}
+		marks[m.passageID(current, nextDir)]++
+		revisited = visited[next]
+		visited[next] = true
+		current = next
+		entrance = (nextDir + 2) % 4
+		walk = append(walk, current)
+	}
+	return walk, true
+}
+
+// Fills in dead ends, other than the start and end, until none remain, and
+// then walks the shortest path through the unfilled cells. In a maze without
+// loops, the unfilled cells are exactly the solution. Returns the walk, the
+// filled cells in the order they were filled, and whether the end was
+// reached.
+func (m *GridMaze) fillDeadEnds() ([]int, []int, bool) {
+	degrees := make([]int, len(m.cells))
+	isFilled := make([]bool, len(m.cells))
+	var filled []int
+	var queue []int
+	var neighbors []int
+	for i := range m.cells {
+		if m.cells[i].state.excluded() {
+			continue
+		}
+		neighbors = m.passageNeighbors(i, neighbors[:0])
+		degrees[i] = len(neighbors)
+		if degrees[i] <= 1 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) != 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if (current == m.startCellIndex) || (current == m.endCellIndex) {
+			continue
+		}
+		isFilled[current] = true
+		filled = append(filled, current)
+		neighbors = m.passageNeighbors(current, neighbors[:0])
+		for _, n := range neighbors {
+			if isFilled[n] {
+				continue
+			}
+			degrees[n]--
+			if degrees[n] == 1 {
+				queue = append(queue, n)
+			}
+		}
+	}
+	distances, parents := m.breadthFirstSearch(m.startCellIndex,
+		func(from, to int) bool {
+			return !isFilled[to] && (m.directionBetween(from, to) >= 0)
+		})
+	walk := pathFromParents(distances, parents, m.endCellIndex)
+	if walk == nil {
+		return []int{m.startCellIndex}, filled, false
+	}
+	return walk, filled, true
+}
@@ -0,0 +1,180 @@
+package maze
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+// Fails the test unless the solution's walk is made of legal moves starting
+// at the start, and ends at the end if the solution claims to be solved.
+func checkHumanSolution(t *testing.T, m *GridMaze, s *HumanSolution) {
+	if !s.Solved {
+		if len(s.Walk) == 0 {
+			t.Fatalf("%s solver returned an empty walk", s.Solver)
+		}
+		checkWalk(t, m, s.Walk, m.StartCell(), s.Walk[len(s.Walk)-1])
+		return
+	}
+	checkWalk(t, m, s.Walk, m.StartCell(), m.EndCell())
+}
+
+func TestSolveLikeHuman(t *testing.T) {
+	tests := []struct {
+		name    string
+		erosion int
+		// The solvers that must always reach the end.
+		mustSolve []HumanSolver
+	}{
+		{"perfect", 0, []HumanSolver{SolverLeftHand, SolverRightHand,
+			SolverTremaux, SolverDeadEndFilling}},
+		{"braided", 5, []HumanSolver{SolverTremaux, SolverDeadEndFilling}},
+	}
+	solvers := []HumanSolver{SolverLeftHand, SolverRightHand, SolverPledge,
+		SolverTremaux, SolverDeadEndFilling}
+	for _, test := range tests {
+		for seed := int64(1); seed <= 20; seed++ {
+			m := newTestMaze(t, 12, 12, seed, test.erosion)
+			for _, solver := range solvers {
+				s, e := m.SolveLikeHuman(solver)
+				if e != nil {
+					t.Fatalf("Failed running %s solver: %s", solver, e)
+				}
+				checkHumanSolution(t, m, s)
+			}
+			for _, solver := range test.mustSolve {
+				s, _ := m.SolveLikeHuman(solver)
+				if !s.Solved {
+					t.Fatalf("%s maze, seed %d: %s solver didn't reach the "+
+						"end", test.name, seed, solver)
+				}
+			}
+		}
+	}
+	m := newTestMaze(t, 4, 4, 1, 0)
+	_, e := m.SolveLikeHuman(SolverDeadEndFilling + 1)
+	if e == nil {
+		t.Fatalf("Didn't get an error using an invalid solver")
+	}
+}
+
+func TestSolveLikeHumanRingTrap(t *testing.T) {
+	// The top row and the outer edge of the maze form a corridor. A ring of
+	// cells around the center hangs off of (2, 0), and the end is in the
+	// center, only reachable from the bottom of the ring. Walls followed
+	// from the start never lead into the center, and Pledge keeps circling
+	// the outer corridor with an ever-growing turn count.
+	m := buildMaze(t, 5, 5, [][2]image.Point{
+		{{0, 0}, {1, 0}},
+		{{1, 0}, {2, 0}},
+		{{2, 0}, {3, 0}},
+		{{3, 0}, {4, 0}},
+		{{2, 0}, {2, 1}},
+		{{1, 1}, {2, 1}},
+		{{2, 1}, {3, 1}},
+		{{3, 1}, {3, 2}},
+		{{3, 2}, {3, 3}},
+		{{3, 3}, {2, 3}},
+		{{2, 3}, {1, 3}},
+		{{1, 3}, {1, 2}},
+		{{1, 2}, {1, 1}},
+		{{2, 3}, {2, 2}},
+		{{4, 0}, {4, 1}},
+		{{4, 1}, {4, 2}},
+		{{4, 2}, {4, 3}},
+		{{4, 3}, {4, 4}},
+		{{4, 4}, {3, 4}},
+		{{3, 4}, {2, 4}},
+		{{2, 4}, {1, 4}},
+		{{1, 4}, {0, 4}},
+		{{0, 4}, {0, 3}},
+		{{0, 3}, {0, 2}},
+		{{0, 2}, {0, 1}},
+	})
+	m.endCellIndex, _ = m.cellIndex(image.Pt(2, 2))
+	tests := []struct {
+		solver HumanSolver
+		solved bool
+	}{
+		{SolverLeftHand, false},
+		{SolverRightHand, false},
+		{SolverPledge, false},
+		{SolverTremaux, true},
+		{SolverDeadEndFilling, true},
+	}
+	for _, test := range tests {
+		s, e := m.SolveLikeHuman(test.solver)
+		if e != nil {
+			t.Fatalf("Failed running %s solver: %s", test.solver, e)
+		}
+		if s.Solved != test.solved {
+			t.Fatalf("%s solver: got solved = %t, expected %t", test.solver,
+				s.Solved, test.solved)
+		}
+		checkHumanSolution(t, m, s)
+	}
+	if !m.WallFollowingFails() {
+		t.Fatalf("Wall following didn't fail with the end inside the ring")
+	}
+}
+
+func TestDeadEndFillingPerfectMaze(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		m := newTestMaze(t, 12, 12, seed, 0)
+		s, _ := m.SolveLikeHuman(SolverDeadEndFilling)
+		path := m.shortestPath(m.startCellIndex, m.endCellIndex)
+		if len(s.Walk) != len(path) {
+			t.Fatalf("Seed %d: walk has %d cells, shortest path has %d",
+				seed, len(s.Walk), len(path))
+		}
+		for i, index := range path {
+			if s.Walk[i] != m.cellPoint(index) {
+				t.Fatalf("Seed %d: walk differs from the shortest path at "+
+					"step %d", seed, i)
+			}
+		}
+	}
+}
+
+func TestTremauxPassageLimit(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		m := newTestMaze(t, 12, 12, seed, 10)
+		s, _ := m.SolveLikeHuman(SolverTremaux)
+		uses := make(map[int]int)
+		for i := 1; i < len(s.Walk); i++ {
+			a, _ := m.cellIndex(s.Walk[i-1])
+			b, _ := m.cellIndex(s.Walk[i])
+			id := m.passageID(a, m.directionBetween(a, b))
+			uses[id]++
+			if uses[id] > 2 {
+				t.Fatalf("Seed %d: passage from %s to %s was walked more "+
+					"than twice", seed, s.Walk[i-1], s.Walk[i])
+			}
+		}
+	}
+}
+
+func TestWallFollowingFails(t *testing.T) {
+	m := newTestMaze(t, 12, 12, 1, 0)
+	if m.WallFollowingFails() {
+		t.Fatalf("Wall following failed in a perfect maze")
+	}
+	// The end is inside a room, which isn't connected to the outer walls.
+	template := "S.........\n" +
+		"..RRRRR...\n" +
+		"..R###R...\n" +
+		"..R#ERR...\n" +
+		"..R###R...\n" +
+		"..RRRRR...\n"
+	mask, e := ParseTextTemplate(strings.NewReader(template))
+	if e != nil {
+		t.Fatalf("Failed parsing template: %s", e)
+	}
+	m, e = NewGridMazeFromMask(mask, 3)
+	if e != nil {
+		t.Fatalf("Failed generating maze from template: %s", e)
+	}
+	if !m.WallFollowingFails() {
+		t.Fatalf("Wall following didn't fail with the end inside a room")
+	}
+}
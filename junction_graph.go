@@ -0,0 +1,271 @@
+package maze
+
+// This file contains a reduced form of a GridMaze's graph, where each
+// corridor is a single edge between junctions, dead ends, and endpoints.
+
+import (
+	"container/heap"
+	"fmt"
+	"image"
+)
+
+// A node in a JunctionGraph.
+type JunctionNode struct {
+	// The column and row of the node's cell.
+	Cell image.Point
+	Kind JunctionKind
+	// The indices of every corridor touching the node, in the graph's
+	// Corridors slice.
+	Corridors []int
+}
+
+// An edge in a JunctionGraph, representing a chain of cells between two
+// nodes.
+type Corridor struct {
+	// The indices of the nodes at either end of the corridor.
+	From, To int
+	// The number of moves needed to get from one end to the other.
+	Length int
+	// Every cell in the corridor, starting with the From node's cell and
+	// ending with the To node's cell.
+	Cells []image.Point
+	// Whether the corridor can be traversed from From to To, and from To to
+	// From. They differ only if the corridor contains one-way passages.
+	Forward, Backward bool
+	// Set if the corridor is a single step through a portal.
+	Portal bool
+}
+
+// A reduced form of a maze's graph, containing only junctions, dead ends,
+// endpoints, waypoints, and portals as nodes, connected by corridors.
+// Searching it is much faster than searching a maze cell by cell. Obtain one
+// using GridMaze.JunctionGraph. The graph is a snapshot, and won't reflect
+// later changes to the maze.
+type JunctionGraph struct {
+	Nodes     []JunctionNode
+	Corridors []Corridor
+	// The indices of the nodes for the maze's start and end cells.
+	Start, End int
+	// The width and height of the maze, in cells.
+	width, height int
+	// Contains the index of the node for each cell of the maze, or -1 for
+	// cells that aren't nodes.
+	cellNodes []int
+}
+
+// Computes the maze's junction graph, with one node for each junction, dead
+// end, endpoint, waypoint, and portal, and one edge for each corridor
+// between them. Corridors that can't be traversed in either direction due
+// to one-way passages are omitted.
+func (m *GridMaze) JunctionGraph() *JunctionGraph {
+	nodes, edges := m.passageGraph(true)
+	toReturn := &JunctionGraph{
+		Nodes:     make([]JunctionNode, len(nodes)),
+		Corridors: make([]Corridor, len(edges)),
+		width:     m.width,
+		height:    m.height,
+		cellNodes: make([]int, len(m.cells)),
+	}
+	for i := range toReturn.cellNodes {
+		toReturn.cellNodes[i] = -1
+	}
+	for i, index := range nodes {
+		toReturn.Nodes[i] = JunctionNode{
+			Cell: m.cellPoint(index),
+			Kind: m.junctionKind(index),
+		}
+		toReturn.cellNodes[index] = i
+	}
+	for i, e := range edges {
+		from := toReturn.cellNodes[e.from]
+		to := toReturn.cellNodes[e.to]
+		c := Corridor{
+			From:     from,
+			To:       to,
+			Length:   e.weight(),
+			Cells:    make([]image.Point, len(e.cells)),
+			Forward:  e.forward,
+			Backward: e.backward,
+			Portal:   e.portal,
+		}
+		for j, index := range e.cells {
+			c.Cells[j] = m.cellPoint(index)
+		}
+		toReturn.Corridors[i] = c
+		toReturn.Nodes[from].Corridors = append(toReturn.Nodes[from].Corridors,
+			i)
+		if to != from {
+			toReturn.Nodes[to].Corridors = append(toReturn.Nodes[to].Corridors,
+				i)
+		}
+	}
+	toReturn.Start = toReturn.cellNodes[m.startCellIndex]
+	toReturn.End = toReturn.cellNodes[m.endCellIndex]
+	return toReturn
+}
+
+// Returns the index of the node at the given cell, or -1 if the cell isn't a
+// node.
+func (g *JunctionGraph) NodeAt(cell image.Point) int {
+	if (cell.X < 0) || (cell.Y < 0) || (cell.X >= g.width) ||
+		(cell.Y >= g.height) {
+		return -1
+	}
+	return g.cellNodes[cell.Y*g.width+cell.X]
+}
+
+// Returns the node at the other end of the corridor from the given node, or
+// -1 if the corridor can't be traversed starting from the node.
+func (g *JunctionGraph) traverse(corridor, node int) int {
+	c := &(g.Corridors[corridor])
+	if (c.From == node) && c.Forward {
+		return c.To
+	}
+	if (c.To == node) && c.Backward {
+		return c.From
+	}
+	return -1
+}
+
+// Returns the number of corridors that can be followed out of the node.
+func (g *JunctionGraph) Exits(node int) int {
+	toReturn := 0
+	for _, corridor := range g.Nodes[node].Corridors {
+		c := &(g.Corridors[corridor])
+		// A loop back to the same node can be entered from either end.
+		if (c.From == node) && (c.To == node) {
+			if c.Forward {
+				toReturn++
+			}
+			if c.Backward {
+				toReturn++
+			}
+			continue
+		}
+		if g.traverse(corridor, node) >= 0 {
+			toReturn++
+		}
+	}
+	return toReturn
+}
+
+// A route through a JunctionGraph.
+type JunctionRoute struct {
+	// The indices of the nodes visited, in order, starting with the first
+	// node and ending with the last.
+	Nodes []int
+	// The indices of the corridors followed; Corridors[i] connects Nodes[i]
+	// and Nodes[i+1].
+	Corridors []int
+	// The total number of moves along the route.
+	Length int
+}
+
+// Finds the route between the two nodes with the fewest total moves, using
+// Dijkstra's algorithm over the corridors. Returns an error if the nodes are
+// invalid or the destination can't be reached.
+func (g *JunctionGraph) Solve(from, to int) (*JunctionRoute, error) {
+	if (from < 0) || (from >= len(g.Nodes)) || (to < 0) ||
+		(to >= len(g.Nodes)) {
+		return nil, fmt.Errorf("Invalid node index")
+	}
+	lengths := make([]int, len(g.Nodes))
+	parentCorridors := make([]int, len(g.Nodes))
+	done := make([]bool, len(g.Nodes))
+	for i := range lengths {
+		lengths[i] = -1
+		parentCorridors[i] = -1
+	}
+	lengths[from] = 0
+	q := &priorityQueue{{from, 0}}
+	for q.Len() != 0 {
+		current := heap.Pop(q).(queueEntry).cell
+		if done[current] {
+			continue
+		}
+		done[current] = true
+		if current == to {
+			break
+		}
+		for _, corridor := range g.Nodes[current].Corridors {
+			next := g.traverse(corridor, current)
+			if (next < 0) || done[next] {
+				continue
+			}
+			length := lengths[current] + g.Corridors[corridor].Length
+			if (lengths[next] >= 0) && (lengths[next] <= length) {
+				continue
+			}
+			lengths[next] = length
+			parentCorridors[next] = corridor
+			heap.Push(q, queueEntry{next, float64(length)})
+		}
+	}
+	if lengths[to] < 0 {
+		a := g.Nodes[from].Cell
+		b := g.Nodes[to].Cell
+		return nil, fmt.Errorf("Cell (%d, %d) can't be reached from cell "+
+			"(%d, %d)", b.X, b.Y, a.X, a.Y)
+	}
+	toReturn := &JunctionRoute{
+		Nodes:  []int{to},
+		Length: lengths[to],
+	}
+	for node := to; node != from; {
+		corridor := parentCorridors[node]
+		c := &(g.Corridors[corridor])
+		if c.To == node {
+			node = c.From
+		} else {
+			node = c.To
+		}
+		toReturn.Nodes = append(toReturn.Nodes, node)
+		toReturn.Corridors = append(toReturn.Corridors, corridor)
+	}
+	reverseInts(toReturn.Nodes)
+	reverseInts(toReturn.Corridors)
+	return toReturn, nil
+}
+
+// Reverses the order of the given slice in place.
+func reverseInts(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// Returns every cell along the route, in order, by expanding each corridor
+// it follows.
+func (g *JunctionGraph) RouteCells(r *JunctionRoute) []image.Point {
+	toReturn := []image.Point{g.Nodes[r.Nodes[0]].Cell}
+	for i, corridor := range r.Corridors {
+		cells := g.Corridors[corridor].Cells
+		if g.Corridors[corridor].From != r.Nodes[i] {
+			// The corridor is followed from its To end.
+			for j := len(cells) - 2; j >= 0; j-- {
+				toReturn = append(toReturn, cells[j])
+			}
+			continue
+		}
+		toReturn = append(toReturn, cells[1:]...)
+	}
+	return toReturn
+}
+
+// Returns the number of nodes along the route, other than the last, where
+// someone following it must choose between more than one way forward,
+// not counting the corridor they arrived through.
+func (g *JunctionGraph) DecisionPoints(r *JunctionRoute) int {
+	toReturn := 0
+	for i := 0; i < (len(r.Nodes) - 1); i++ {
+		exits := g.Exits(r.Nodes[i])
+		// Don't count the way back, if it could be used.
+		if (i > 0) && (g.traverse(r.Corridors[i-1], r.Nodes[i]) >= 0) {
+			exits--
+		}
+		if exits > 1 {
+			toReturn++
+		}
+	}
+	return toReturn
+}
@@ -0,0 +1,114 @@
+package maze
+
+import (
+	"image"
+	"testing"
+)
+
+func TestJunctionGraphSolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		erosion int
+		oneWay  int
+		portals int
+	}{
+		{"perfect", 0, 0, 0},
+		{"braided", 5, 0, 0},
+		{"one-way", 5, 40, 0},
+		{"portals", 0, 0, 2},
+	}
+	for _, test := range tests {
+		for seed := int64(1); seed <= 20; seed++ {
+			m := newTestMaze(t, 15, 15, seed, test.erosion)
+			if test.oneWay > 0 {
+				_, e := m.AddOneWayPassages(test.oneWay, seed)
+				if e != nil {
+					t.Fatalf("Failed adding one-way passages: %s", e)
+				}
+			}
+			if test.portals > 0 {
+				_, e := m.PlacePortals(test.portals, seed)
+				if e != nil {
+					t.Fatalf("Failed placing portals: %s", e)
+				}
+			}
+			g := m.JunctionGraph()
+			r, e := g.Solve(g.Start, g.End)
+			if e != nil {
+				t.Fatalf("%s maze, seed %d: failed solving: %s", test.name,
+					seed, e)
+			}
+			expected := m.distancesFrom(m.startCellIndex)[m.endCellIndex]
+			if r.Length != expected {
+				t.Fatalf("%s maze, seed %d: got length %d, expected %d",
+					test.name, seed, r.Length, expected)
+			}
+			cells := g.RouteCells(r)
+			checkWalk(t, m, cells, m.StartCell(), m.EndCell())
+			if (len(cells) - 1) != r.Length {
+				t.Fatalf("%s maze, seed %d: route has %d moves, but its "+
+					"length is %d", test.name, seed, len(cells)-1, r.Length)
+			}
+		}
+	}
+}
+
+func TestJunctionGraphCorridors(t *testing.T) {
+	m := newTestMaze(t, 15, 15, 1, 5)
+	_, e := m.AddOneWayPassages(40, 1)
+	if e != nil {
+		t.Fatalf("Failed adding one-way passages: %s", e)
+	}
+	g := m.JunctionGraph()
+	for i, c := range g.Corridors {
+		if (len(c.Cells) - 1) != c.Length {
+			t.Fatalf("Corridor %d has %d cells, but length %d", i,
+				len(c.Cells), c.Length)
+		}
+		if c.Forward {
+			checkWalk(t, m, c.Cells, g.Nodes[c.From].Cell, g.Nodes[c.To].Cell)
+		}
+		if c.Backward {
+			reversed := make([]image.Point, len(c.Cells))
+			for j, p := range c.Cells {
+				reversed[len(c.Cells)-1-j] = p
+			}
+			checkWalk(t, m, reversed, g.Nodes[c.To].Cell,
+				g.Nodes[c.From].Cell)
+		}
+	}
+}
+
+func TestJunctionGraphNodeAt(t *testing.T) {
+	m := newTestMaze(t, 8, 8, 1, 0)
+	g := m.JunctionGraph()
+	if g.NodeAt(m.StartCell()) != g.Start {
+		t.Fatalf("NodeAt didn't return the start node")
+	}
+	if g.NodeAt(m.EndCell()) != g.End {
+		t.Fatalf("NodeAt didn't return the end node")
+	}
+	for _, p := range []image.Point{{-1, 0}, {0, -1}, {8, 0}, {0, 8}} {
+		if g.NodeAt(p) != -1 {
+			t.Fatalf("NodeAt returned a node for cell %s, outside the maze",
+				p)
+		}
+	}
+	for i, node := range g.Nodes {
+		if g.NodeAt(node.Cell) != i {
+			t.Fatalf("NodeAt(%s) didn't return node %d", node.Cell, i)
+		}
+	}
+	// Cells in the middle of a corridor aren't nodes.
+	for _, c := range g.Corridors {
+		for _, p := range c.Cells[1 : len(c.Cells)-1] {
+			if g.NodeAt(p) != -1 {
+				t.Fatalf("NodeAt returned a node for corridor cell %s", p)
+			}
+		}
+	}
+	_, e := g.Solve(-1, g.End)
+	if e == nil {
+		t.Fatalf("Didn't get an error solving from an invalid node")
+	}
+}
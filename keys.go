@@ -0,0 +1,309 @@
+package maze
+
+// This file contains a puzzle layer for GridMazes, consisting of locked doors
+// on some passages, along with keys that open them.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"time"
+)
+
+// The colors used to draw keys and their doors. Key n uses color
+// n % len(keyColors).
+var keyColors = []color.Color{
+	color.RGBA{220, 170, 0, 255},
+	color.RGBA{40, 110, 230, 255},
+	color.RGBA{30, 170, 60, 255},
+	color.RGBA{190, 40, 190, 255},
+	color.RGBA{230, 110, 20, 255},
+	color.RGBA{20, 180, 180, 255},
+}
+
+// Returns the color used to draw the given key and its door.
+func KeyColor(key int) color.Color {
+	return keyColors[key%len(keyColors)]
+}
+
+// Returns an identifier for the passage in the given direction of the given
+// cell. The ID is the same regardless of which of the two cells sharing the
+// passage it's computed from. Returns -1 if the direction leads off of the
+// maze's border.
+func (m *GridMaze) passageID(cellIndex, direction int) int {
+	neighbor := m.neighborInDirection(cellIndex, direction)
+	if neighbor < 0 {
+		return -1
+	}
+	// Passages are identified by the cell above or to the left of them.
+	switch direction {
+	case 0:
+		return neighbor * 2
+	case 1:
+		return neighbor*2 + 1
+	case 2:
+		return cellIndex * 2
+	case 3:
+		return cellIndex*2 + 1
+	}
+	return -1
+}
+
+// Returns the color of the key or door drawn at the given pixel in the cell,
+// or nil if there isn't one. Keys are drawn as squares in the middle of the
+// cell, and doors are drawn where a wall would be.
+func (c *gridMazeCell) puzzleMarkerAt(x, y int) color.Color {
+	m := c.parent
+	cellPixels := m.cellPixels
+	key, ok := m.keyCells[c.cellIndex]
+	if ok {
+		low := cellPixels / 3
+		high := cellPixels - 1 - low
+		if (x >= low) && (x <= high) && (y >= low) && (y <= high) {
+			return KeyColor(key)
+		}
+	}
+	dir := -1
+	last := cellPixels - 1
+	if (y > 0) && (y < last) {
+		if x == 0 {
+			dir = 0
+		} else if x == last {
+			dir = 2
+		}
+	} else if (x > 0) && (x < last) {
+		if y == 0 {
+			dir = 1
+		} else if y == last {
+			dir = 3
+		}
+	}
+	if (dir < 0) || c.walls[dir] {
+		return nil
+	}
+	key, ok = m.doors[m.passageID(c.cellIndex, dir)]
+	if !ok {
+		return nil
+	}
+	return KeyColor(key)
+}
+
+// Returns a function for use with breadthFirstSearch that prevents moving
+// through doors whose keys haven't been collected.
+func (m *GridMaze) doorFilter(collected []bool) func(from, to int) bool {
+	return func(from, to int) bool {
+		if len(m.doors) == 0 {
+			return true
+		}
+		id := m.passageID(from, m.directionBetween(from, to))
+		key, ok := m.doors[id]
+		return !ok || collected[key]
+	}
+}
+
+// Removes all keys and doors from the maze.
+func (m *GridMaze) ClearKeysAndDoors() {
+	m.doors = nil
+	m.keyCells = nil
+}
+
+// Places the given number of locked doors on passages along the solution, and
+// a key for each door. Door n can only be opened using key n, and the doors
+// are numbered in the order they're encountered along the solution. Each key
+// is placed somewhere that can be reached using only the keys before it,
+// preferring cells far away from the solution path, so the maze is always
+// solvable. Replaces any previously placed keys and doors. If the given seed
+// is not positive, a new seed will be selected based on the current time in
+// nanoseconds.
+func (m *GridMaze) PlaceKeysAndDoors(count int, seed int64) error {
+	m.ClearKeysAndDoors()
+	if count <= 0 {
+		return nil
+	}
+	path := m.shortestPath(m.startCellIndex, m.endCellIndex)
+	if path == nil {
+		return fmt.Errorf("The end can't be reached from the start")
+	}
+	// Doors can only be placed between adjacent cells, and not on portals.
+	// The first passage is skipped, so there's always somewhere other than
+	// the start to put the first key.
+	var steps []int
+	for i := 2; i < len(path); i++ {
+		if m.isPassageStep(path[i-1], path[i]) {
+			steps = append(steps, i-1)
+		}
+	}
+	if count > len(steps) {
+		return fmt.Errorf("The solution is too short for %d doors", count)
+	}
+	if seed <= 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	onPath := make([]bool, len(m.cells))
+	for _, index := range path {
+		onPath[index] = true
+	}
+
+	// Split the solution's passages into count equal sections, and place a
+	// door at a random passage in each. The door for steps[j] is on the
+	// passage between path[steps[j]] and the following cell.
+	doors := make(map[int]int)
+	for key := 0; key < count; key++ {
+		low := (key * len(steps)) / count
+		high := ((key + 1) * len(steps)) / count
+		i := steps[low+rng.Intn(high-low)]
+		dir := m.directionBetween(path[i], path[i+1])
+		doors[m.passageID(path[i], dir)] = key
+	}
+	m.doors = doors
+
+	// Place each key in the area that's newly reachable using the previous
+	// keys, as far from the solution path as possible.
+	keyCells := make(map[int]int)
+	collected := make([]bool, count)
+	var previouslyReachable []int
+	for key := 0; key < count; key++ {
+		reachable, _ := m.breadthFirstSearch(m.startCellIndex,
+			m.doorFilter(collected))
+		keyCell := m.chooseKeyCell(reachable, previouslyReachable, onPath,
+			keyCells)
+		if keyCell < 0 {
+			m.ClearKeysAndDoors()
+			return fmt.Errorf("Couldn't find a place for key %d", key)
+		}
+		keyCells[keyCell] = key
+		collected[key] = true
+		previouslyReachable = reachable
+	}
+	m.keyCells = keyCells
+	return nil
+}
+
+// Returns the index of the best cell for a key, or -1 if there isn't one.
+// The cell must be reachable, and shouldn't already contain a key. Cells that
+// weren't previously reachable are preferred, followed by cells far from the
+// solution path. Used by PlaceKeysAndDoors.
+func (m *GridMaze) chooseKeyCell(reachable, previouslyReachable []int,
+	onPath []bool, keyCells map[int]int) int {
+	// Find each reachable cell's distance from the solution path, by
+	// searching outward from every reachable path cell at once.
+	distances := make([]int, len(m.cells))
+	queue := make([]int, 0, len(m.cells))
+	for i := range distances {
+		distances[i] = -1
+		if onPath[i] && (reachable[i] >= 0) {
+			distances[i] = 0
+			queue = append(queue, i)
+		}
+	}
+	var neighbors []int
+	for len(queue) != 0 {
+		current := queue[0]
+		queue = queue[1:]
+		neighbors = m.openNeighbors(current, neighbors[:0])
+		for _, n := range neighbors {
+			if (distances[n] >= 0) || (reachable[n] < 0) {
+				continue
+			}
+			distances[n] = distances[current] + 1
+			queue = append(queue, n)
+		}
+	}
+	best := -1
+	bestIsNew := false
+	for i, d := range distances {
+		if (d < 0) || (i == m.startCellIndex) {
+			continue
+		}
+		_, hasKey := keyCells[i]
+		if hasKey {
+			continue
+		}
+		isNew := (previouslyReachable == nil) || (previouslyReachable[i] < 0)
+		if best >= 0 {
+			if bestIsNew && !isNew {
+				continue
+			}
+			if (bestIsNew == isNew) && (d <= distances[best]) {
+				continue
+			}
+		}
+		best = i
+		bestIsNew = isNew
+	}
+	return best
+}
+
+// A plan for collecting keys in order to reach the end of a maze with doors.
+type KeySolution struct {
+	// The numbers of the keys, in the order they're collected.
+	Order []int
+	// Every cell visited, starting at the start and ending at the end. May
+	// visit some cells more than once.
+	Walk []image.Point
+	// The number of moves in the walk.
+	Length int
+}
+
+// Plans a route from the start to the end that collects keys as needed to
+// open doors. Keys are collected by repeatedly walking to the nearest
+// reachable key until the end can be reached. Returns an error if the end
+// can't be reached even after collecting every reachable key.
+func (m *GridMaze) SolveKeysAndDoors() (*KeySolution, error) {
+	keyCount := 0
+	for _, key := range m.doors {
+		if (key + 1) > keyCount {
+			keyCount = key + 1
+		}
+	}
+	for _, key := range m.keyCells {
+		if (key + 1) > keyCount {
+			keyCount = key + 1
+		}
+	}
+	collected := make([]bool, keyCount)
+	toReturn := &KeySolution{}
+	current := m.startCellIndex
+	walk := []int{current}
+	for {
+		distances, parents := m.breadthFirstSearch(current,
+			m.doorFilter(collected))
+		target := -1
+		if distances[m.endCellIndex] >= 0 {
+			target = m.endCellIndex
+		} else {
+			// Head to the nearest key we haven't collected yet.
+			for cell, key := range m.keyCells {
+				if collected[key] || (distances[cell] < 0) {
+					continue
+				}
+				if (target < 0) || (distances[cell] < distances[target]) ||
+					((distances[cell] == distances[target]) &&
+						(cell < target)) {
+					target = cell
+				}
+			}
+		}
+		if target < 0 {
+			return nil, fmt.Errorf("The end can't be reached, even after " +
+				"collecting every reachable key")
+		}
+		path := pathFromParents(distances, parents, target)
+		walk = append(walk, path[1:]...)
+		current = target
+		if target == m.endCellIndex {
+			break
+		}
+		key := m.keyCells[target]
+		collected[key] = true
+		toReturn.Order = append(toReturn.Order, key)
+	}
+	toReturn.Walk = make([]image.Point, len(walk))
+	for i, index := range walk {
+		toReturn.Walk[i] = m.cellPoint(index)
+	}
+	toReturn.Length = len(walk) - 1
+	return toReturn, nil
+}
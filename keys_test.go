@@ -0,0 +1,109 @@
+package maze
+
+import (
+	"image"
+	"testing"
+)
+
+// Fails the test unless the walk only passes through doors after collecting
+// their keys. Keys are collected by stepping into their cells.
+func checkDoorsOpened(t *testing.T, m *GridMaze, walk []image.Point) {
+	collected := make(map[int]bool)
+	for i := 0; i < len(walk); i++ {
+		b, _ := m.cellIndex(walk[i])
+		if i > 0 {
+			a, _ := m.cellIndex(walk[i-1])
+			key, isDoor := m.doors[m.passageID(a, m.directionBetween(a, b))]
+			if isDoor && !collected[key] {
+				t.Fatalf("Walk passes through door %d, from %s to %s, "+
+					"without its key", key, walk[i-1], walk[i])
+			}
+		}
+		key, hasKey := m.keyCells[b]
+		if hasKey {
+			collected[key] = true
+		}
+	}
+}
+
+func TestSolveKeysAndDoorsCorridor(t *testing.T) {
+	// A corridor along the top row leading to the end at the bottom right,
+	// with a door just before the end. The key is at the end of a dead end
+	// leading back along the bottom row.
+	m := buildMaze(t, 5, 2, [][2]image.Point{
+		{{0, 0}, {1, 0}},
+		{{1, 0}, {2, 0}},
+		{{2, 0}, {3, 0}},
+		{{3, 0}, {4, 0}},
+		{{4, 0}, {4, 1}},
+		{{3, 1}, {4, 1}},
+		{{2, 0}, {2, 1}},
+		{{2, 1}, {1, 1}},
+		{{1, 1}, {0, 1}},
+	})
+	door, _ := m.cellIndex(image.Pt(3, 0))
+	key, _ := m.cellIndex(image.Pt(0, 1))
+	m.doors = map[int]int{m.passageID(door, 2): 0}
+	m.keyCells = map[int]int{key: 0}
+	s, e := m.SolveKeysAndDoors()
+	if e != nil {
+		t.Fatalf("Failed solving maze with a door: %s", e)
+	}
+	expected := []image.Point{{0, 0}, {1, 0}, {2, 0}, {2, 1}, {1, 1},
+		{0, 1}, {1, 1}, {2, 1}, {2, 0}, {3, 0}, {4, 0}, {4, 1}}
+	if s.Length != (len(expected) - 1) {
+		t.Fatalf("Got a walk with %d moves, expected %d", s.Length,
+			len(expected)-1)
+	}
+	for i, p := range expected {
+		if s.Walk[i] != p {
+			t.Fatalf("Step %d of the walk is %s, expected %s", i, s.Walk[i],
+				p)
+		}
+	}
+	if (len(s.Order) != 1) || (s.Order[0] != 0) {
+		t.Fatalf("Got key order %v, expected [0]", s.Order)
+	}
+	// Without the key, the end can't be reached.
+	m.keyCells = nil
+	_, e = m.SolveKeysAndDoors()
+	if e == nil {
+		t.Fatalf("Didn't get an error solving a maze without a needed key")
+	}
+}
+
+func TestPlaceKeysAndDoors(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		m := newTestMaze(t, 12, 12, seed, 0)
+		e := m.PlaceKeysAndDoors(3, seed)
+		if e != nil {
+			t.Fatalf("Seed %d: failed placing keys and doors: %s", seed, e)
+		}
+		if (len(m.doors) != 3) || (len(m.keyCells) != 3) {
+			t.Fatalf("Seed %d: placed %d doors and %d keys, expected 3 of "+
+				"each", seed, len(m.doors), len(m.keyCells))
+		}
+		// The solution passes through every door, so the end can't be
+		// reached without keys.
+		path := m.shortestPath(m.startCellIndex, m.endCellIndex)
+		distances, _ := m.breadthFirstSearch(m.startCellIndex,
+			m.doorFilter(make([]bool, 3)))
+		if (path == nil) || (distances[m.endCellIndex] >= 0) {
+			t.Fatalf("Seed %d: the end can be reached without keys", seed)
+		}
+		s, e := m.SolveKeysAndDoors()
+		if e != nil {
+			t.Fatalf("Seed %d: failed solving: %s", seed, e)
+		}
+		checkWalk(t, m, s.Walk, m.StartCell(), m.EndCell())
+		checkDoorsOpened(t, m, s.Walk)
+		if s.Length != (len(s.Walk) - 1) {
+			t.Fatalf("Seed %d: walk has %d cells, but length %d", seed,
+				len(s.Walk), s.Length)
+		}
+	}
+	m := newTestMaze(t, 3, 1, 1, 0)
+	if m.PlaceKeysAndDoors(5, 1) == nil {
+		t.Fatalf("Didn't get an error placing too many doors")
+	}
+}
@@ -38,6 +38,23 @@ type MazeInfo struct {
 	EndAngle float32
 	// Contains information about the maze.
 	DebugInfo string
+	// Information about every start and end cell, for mazes with more than
+	// one entrance or exit. The first start and end are not necessarily the
+	// ones described by StartPoint and EndPoint.
+	Starts []EndpointInfo
+	Ends   []EndpointInfo
+}
+
+// Describes one of a maze's entrances or exits.
+type EndpointInfo struct {
+	// The column and row of the endpoint's cell.
+	Cell image.Point
+	// The point at which the arrow for the endpoint should be drawn. May be on
+	// the maze boundary.
+	Point image.Point
+	// The direction the arrow should face. Negative if an arrow shouldn't be
+	// drawn.
+	Angle float32
 }
 
 // Implements the disjoint set data structure from CLRS.
@@ -90,11 +107,21 @@ func (s cellState) String() string {
 		return "solutionPath"
 	case 2:
 		return "excluded"
+	case 3:
+		return "wall"
 	}
 	return fmt.Sprintf("Unknown cellState: %d", uint8(s))
 }
 
+// Returns true if paths can't pass through the cell, either because it's
+// outside of the maze or because it's a solid wall.
 func (s cellState) excluded() bool {
+	return (s == 2) || (s == 3)
+}
+
+// Returns true if the cell isn't part of the maze at all. Unlike solid wall
+// cells, entrances and exits may open onto these cells.
+func (s cellState) outside() bool {
 	return s == 2
 }
 
@@ -104,6 +131,10 @@ type gridMazeCell struct {
 	// Whether each of the cell's "walls" are present. The order is left, top,
 	// right, bottom. Each entry is true if the wall is there.
 	walls [4]bool
+	// Each entry is true if the passage in the corresponding direction is
+	// one-way, and can only be used to enter this cell. Only meaningful if
+	// the wall in that direction is absent.
+	noExit [4]bool
 	// The index of the cell in the parent maze.
 	cellIndex int
 	// The maze containing this cell
@@ -112,6 +143,11 @@ type gridMazeCell struct {
 	state cellState
 	// Used for the disjoint-set method of maze generation.
 	djSet *disjointSet
+	// If true, the cell is part of an open "room", and never has walls
+	// between itself and neighboring room cells.
+	room bool
+	// The type of ground in the cell, which affects the cost of entering it.
+	terrain Terrain
 }
 
 func (c *gridMazeCell) ColorModel() color.Model {
@@ -147,6 +183,25 @@ func (c *gridMazeCell) At(x, y int) color.Color {
 		return color.Black
 		//return color.White
 	}
+	// Open space is white, unless the cell has special terrain.
+	var background color.Color = color.White
+	if c.terrain != TerrainNormal {
+		background = c.terrain.color()
+	}
+	// Markers for one-way passages, portals, keys, and doors are drawn on
+	// top of the floor and walls, in that order of precedence.
+	if c.parent.hasOneWay && c.oneWayArrowAt(x, y) {
+		return color.Black
+	}
+	if (len(c.parent.portals) != 0) && c.portalMarkerAt(x, y) {
+		return portalColor(c.parent.portals[c.cellIndex])
+	}
+	if (len(c.parent.doors) != 0) || (len(c.parent.keyCells) != 0) {
+		markerColor := c.puzzleMarkerAt(x, y)
+		if markerColor != nil {
+			return markerColor
+		}
+	}
 
 	if x == 0 {
 		if y == 0 {
@@ -154,20 +209,20 @@ func (c *gridMazeCell) At(x, y int) color.Color {
 			if c.cornerSet(0) {
 				return color.Black
 			}
-			return color.White
+			return background
 		}
 		if y == (cellPixels - 1) {
 			// Bottom left corner
 			if c.cornerSet(3) {
 				return color.Black
 			}
-			return color.White
+			return background
 		}
 		// The pixel is along the left wall
 		if c.walls[0] {
 			return color.Black
 		}
-		return color.White
+		return background
 	}
 	if x == (cellPixels - 1) {
 		if y == 0 {
@@ -175,20 +230,20 @@ func (c *gridMazeCell) At(x, y int) color.Color {
 			if c.cornerSet(1) {
 				return color.Black
 			}
-			return color.White
+			return background
 		}
 		if y == (cellPixels - 1) {
 			// Bottom right corner
 			if c.cornerSet(2) {
 				return color.Black
 			}
-			return color.White
+			return background
 		}
 		// The pixel is along the right wall
 		if c.walls[2] {
 			return color.Black
 		}
-		return color.White
+		return background
 	}
 	// We already checked corners along with the left and right walls, so we
 	// don't need to check them for the top and bottom walls.
@@ -196,18 +251,18 @@ func (c *gridMazeCell) At(x, y int) color.Color {
 		if c.walls[1] {
 			return color.Black
 		}
-		return color.White
+		return background
 	}
 	if y == (cellPixels - 1) {
 		if c.walls[3] {
 			return color.Black
 		}
-		return color.White
+		return background
 	}
 	// At this point, we're not along any wall. First, everything is simply
 	// white if we're not "selected"
 	if c.state != 1 {
-		return color.White
+		return background
 	}
 	// Selected cells are red.
 	return color.RGBA{
@@ -226,6 +281,7 @@ func initGridMazeCell(c *gridMazeCell, cellIndex int, parent *GridMaze) {
 	c.djSet = newDisjointSet()
 	for i := range c.walls {
 		c.walls[i] = true
+		c.noExit[i] = false
 	}
 }
 
@@ -256,6 +312,39 @@ type GridMaze struct {
 	randomSeed int64
 	// The time required for the last generation.
 	generationTime float64
+	// If non-nil, this will be notified about each step of generation.
+	observer GenerationObserver
+	// Determines how the start and end cells are chosen after generation.
+	endpointMode EndpointMode
+	// The indices of cells marked as possible start or end cells by a
+	// template. Empty if no template was used.
+	startCandidates []int
+	endCandidates   []int
+	// The indices of cells the solution must pass through, in order.
+	waypoints []int
+	// The indices of every start and end cell, for mazes with multiple
+	// entrances or exits. Empty if the maze only has one of each. If
+	// non-empty, these include startCellIndex and endCellIndex.
+	startIndices []int
+	endIndices   []int
+	// Maps passage IDs (see passageID) to the number of the key that opens
+	// the door on the passage. Nil if the maze has no doors.
+	doors map[int]int
+	// Maps cell indices to the number of the key in the cell.
+	keyCells map[int]int
+	// Maps the index of each portal cell to the index of its pair in
+	// portalPairs.
+	portals map[int]int
+	// Each pair of linked portal cells, in the order they were added.
+	portalPairs [][2]int
+	// Overrides the cost of entering cells with each type of terrain.
+	terrainCosts map[Terrain]int
+	// Maps passage IDs (see passageID) to the cost of moving through the
+	// passage, for passages with a cost set using SetPassageCost.
+	passageCosts map[int]int
+	// Set if any passages may be one-way. Used to avoid checking for arrows
+	// when drawing mazes without any one-way passages.
+	hasOneWay bool
 }
 
 // Allocates but does not initialize any maze cell contents.
@@ -306,48 +395,127 @@ func (m *GridMaze) SetCellPixelsWide(v int) error {
 	return nil
 }
 
-// We'll convert template colors to values of this type.
-type templateCellType uint8
+// Receives notifications about the steps taken while generating a GridMaze.
+// Every way of generating a maze must notify the observer about each wall it
+// removes, in the order the walls are removed.
+type GenerationObserver interface {
+	// Called at the start of generation, after all of the maze's walls have
+	// been set.
+	GenerationStarted(m *GridMaze)
+	// Called after the wall in the given direction (0 = left, 1 = up,
+	// 2 = right, 3 = down) of the cell at cellIndex has been removed. The
+	// neighboring cell's wall will have been removed, too.
+	WallRemoved(cellIndex, direction int)
+}
 
-func (t templateCellType) String() string {
+// Sets the observer that will be notified about the steps taken the next time
+// the maze is generated. May be nil to stop observing generation.
+func (m *GridMaze) SetGenerationObserver(o GenerationObserver) {
+	m.observer = o
+}
+
+// Identifies what a single cell in a maze template represents.
+type TemplateCellType uint8
+
+const (
+	// A normal cell that will be part of the maze's pathways.
+	TemplateNormal TemplateCellType = iota
+	// A cell that isn't part of the maze at all.
+	TemplateExcluded
+	// A cell that may be chosen as the maze's start.
+	TemplateStart
+	// A cell that may be chosen as the maze's end.
+	TemplateEnd
+	// A cell that the solution must pass through.
+	TemplateWaypoint
+	// A cell in an open room, with no walls between it and other room cells.
+	TemplateRoom
+	// A solid wall within the maze.
+	TemplateWall
+	// A normal cell containing mud. See the Terrain type.
+	TemplateMud
+	// A normal cell containing water.
+	TemplateWater
+	// A normal cell containing stairs.
+	TemplateStairs
+)
+
+func (t TemplateCellType) String() string {
 	switch t {
-	case 0:
+	case TemplateNormal:
 		return "valid"
-	case 1:
+	case TemplateExcluded:
 		return "excluded"
-	case 2:
+	case TemplateStart:
 		return "startCandidate"
-	case 3:
+	case TemplateEnd:
 		return "endCandidate"
+	case TemplateWaypoint:
+		return "waypoint"
+	case TemplateRoom:
+		return "room"
+	case TemplateWall:
+		return "wall"
+	case TemplateMud:
+		return "mud"
+	case TemplateWater:
+		return "water"
+	case TemplateStairs:
+		return "stairs"
 	}
 	return fmt.Sprintf("Invalid template cell type: %d", uint8(t))
 }
 
 // Converts an arbitrary color to what the type of cell represents. See the
-// comment on NewGridMazeFromTemplate for how the mapping works.
-func colorToTemplateCellType(c color.Color) templateCellType {
+// comment on NewGridMazeFromTemplate for how the mapping works. Returns an
+// error if the color doesn't correspond to any type of cell.
+func colorToTemplateCellType(c color.Color) (TemplateCellType, error) {
 	r, g, b, _ := c.RGBA()
 	r = r >> 8
 	g = g >> 8
 	b = b >> 8
 	// Black pixels represent excluded cells
 	if (r == 0) && (g == 0) && (b == 0) {
-		return 1
+		return TemplateExcluded, nil
 	}
 	// Green pixels are possible starting cells
 	if (r == 0) && (g > 200) && (b == 0) {
-		return 2
+		return TemplateStart, nil
 	}
 	// Red pixels are possible ending cells
 	if (r > 200) && (g == 0) && (b == 0) {
-		return 3
+		return TemplateEnd, nil
+	}
+	// Blue pixels are waypoints
+	if (r == 0) && (g == 0) && (b > 200) {
+		return TemplateWaypoint, nil
+	}
+	// Yellow pixels are solid walls
+	if (r > 200) && (g > 200) && (b == 0) {
+		return TemplateWall, nil
+	}
+	// Cyan pixels are water
+	if (r == 0) && (g > 200) && (b > 200) {
+		return TemplateWater, nil
+	}
+	// Magenta pixels are stairs
+	if (r > 200) && (g == 0) && (b > 200) {
+		return TemplateStairs, nil
+	}
+	// Brown pixels are mud
+	if (r >= 100) && (r <= 200) && (g >= 40) && (g <= 100) && (b == 0) {
+		return TemplateMud, nil
 	}
 	// White pixels are standard maze cells
 	if (r == 255) && (g == 255) && (b == 255) {
-		return 0
+		return TemplateNormal, nil
 	}
-	// All other colors are treated as standard for now
-	return 0
+	// Any other shade of gray is part of a room
+	if (r == g) && (g == b) {
+		return TemplateRoom, nil
+	}
+	return TemplateNormal, fmt.Errorf("Unsupported template color (RGB = "+
+		"%d, %d, %d)", r, g, b)
 }
 
 // Uses a "template" image to generate a maze. Each pixel in the template will
@@ -355,16 +523,39 @@ func colorToTemplateCellType(c color.Color) templateCellType {
 // positive. The template image must use the following format:
 //   - Green pixels are possible starting points (RGB = 0, >200, 0)
 //   - Red pixels are possible ending points (RGB = >200, 0, 0)
+//   - Blue pixels are waypoints that the solution must pass through, in the
+//     order they appear in the image, left to right and top to bottom
+//     (RGB = 0, 0, >200)
+//   - Gray pixels are part of open "rooms" with no walls between adjacent
+//     room cells (RGB = x, x, x, where 0 < x < 255)
+//   - Yellow pixels are solid walls within the maze (RGB = >200, >200, 0)
+//   - Brown pixels are normal cells containing mud (RGB = 100-200, 40-100, 0)
+//   - Cyan pixels are normal cells containing water (RGB = 0, >200, >200)
+//   - Magenta pixels are normal cells containing stairs (RGB = >200, 0, >200)
 //   - Black pixels are excluded cells
 //   - White pixels are "normal" cells that will be part of the maze.
-//   - Any other color will be treated as "excluded" by default, but this is
-//     subject to change.
+//   - Any other color is an error.
 func NewGridMazeFromTemplate(templatePic image.Image, seed int64) (*GridMaze,
 	error) {
-	bounds := templatePic.Bounds().Canon()
-	width := bounds.Dx()
-	height := bounds.Dy()
-	toReturn, e := allocateMaze(width, height)
+	mask, e := TemplateMaskFromImage(templatePic, nil)
+	if e != nil {
+		return nil, e
+	}
+	return NewGridMazeFromMask(mask, seed)
+}
+
+// Generates a maze with one cell for each cell in the given template mask. The
+// given seed will be ignored if not positive. See the comment on
+// NewGridMazeFromTemplate for how each type of template cell is treated.
+// Returns a *DisconnectedTemplateError if any part of the template can't be
+// reached from the start; see TemplateMask.ResolveIslands for ways to fix
+// such templates.
+func NewGridMazeFromMask(mask *TemplateMask, seed int64) (*GridMaze, error) {
+	e := mask.CheckConnectivity()
+	if e != nil {
+		return nil, e
+	}
+	toReturn, e := allocateMaze(mask.width, mask.height)
 	if e != nil {
 		return nil, e
 	}
@@ -374,31 +565,37 @@ func NewGridMazeFromTemplate(templatePic image.Image, seed int64) (*GridMaze,
 
 	possibleStartIndices := make([]int, 0, 100)
 	possibleEndIndices := make([]int, 0, 100)
-	cellIndex := -1
-	for row := bounds.Min.Y; row < bounds.Max.Y; row++ {
-		for col := bounds.Min.X; col < bounds.Max.X; col++ {
-			cellIndex++
-			cellType := colorToTemplateCellType(templatePic.At(col, row))
-			switch cellType {
-			case 0:
-				// No need to do anything with standard cells
-				break
-			case 1:
-				// Type 1 = excluded cells
-				toReturn.cells[cellIndex].state = 2
-			case 2:
-				// Type 2 = possible start cell.
-				possibleStartIndices = append(possibleStartIndices, cellIndex)
-			case 3:
-				// Type 3 = possible end cell.
-				possibleEndIndices = append(possibleEndIndices, cellIndex)
-			default:
-				return nil, fmt.Errorf("Invalid template pixel type (%s)",
-					cellType)
-			}
+	for cellIndex, cellType := range mask.cells {
+		switch cellType {
+		case TemplateNormal:
+			// No need to do anything with standard cells
+			break
+		case TemplateExcluded:
+			toReturn.cells[cellIndex].state = 2
+		case TemplateStart:
+			possibleStartIndices = append(possibleStartIndices, cellIndex)
+		case TemplateEnd:
+			possibleEndIndices = append(possibleEndIndices, cellIndex)
+		case TemplateWaypoint:
+			toReturn.waypoints = append(toReturn.waypoints, cellIndex)
+		case TemplateRoom:
+			toReturn.cells[cellIndex].room = true
+		case TemplateWall:
+			toReturn.cells[cellIndex].state = 3
+		case TemplateMud:
+			toReturn.cells[cellIndex].terrain = TerrainMud
+		case TemplateWater:
+			toReturn.cells[cellIndex].terrain = TerrainWater
+		case TemplateStairs:
+			toReturn.cells[cellIndex].terrain = TerrainStairs
+		default:
+			return nil, fmt.Errorf("Invalid template cell type (%s)",
+				cellType)
 		}
 	}
 
+	toReturn.startCandidates = possibleStartIndices
+	toReturn.endCandidates = possibleEndIndices
 	rng := rand.New(rand.NewSource(seed))
 	if len(possibleStartIndices) != 0 {
 		toReturn.startCellIndex = possibleStartIndices[rng.Intn(
@@ -457,7 +654,7 @@ func (m *GridMaze) initDisjointNeighbors() error {
 			}
 			// Create an entry for the neighbor to the right, except if the
 			// neighbor is excluded.
-			if (col != (m.width - 1)) && (m.cells[index+1].state != 2) {
+			if (col != (m.width - 1)) && !m.cells[index+1].state.excluded() {
 				m.neighbors = append(m.neighbors, gridNeighborInfo{
 					baseIndex:         index,
 					neighborDirection: 2,
@@ -468,7 +665,7 @@ func (m *GridMaze) initDisjointNeighbors() error {
 			}
 			// Create an entry for the neighbor below, also making sure it
 			// isn't excluded.
-			if m.cells[index+m.width].state != 2 {
+			if !m.cells[index+m.width].state.excluded() {
 				m.neighbors = append(m.neighbors, gridNeighborInfo{
 					baseIndex:         index,
 					neighborDirection: 3,
@@ -479,6 +676,35 @@ func (m *GridMaze) initDisjointNeighbors() error {
 	return nil
 }
 
+// Removes the walls between all adjacent room cells, and joins their disjoint
+// sets so that generation treats each room as a single connected area. Must
+// only be called by RegenerateFromSeed, after initializing the cells.
+func (m *GridMaze) openRooms() {
+	for i := range m.cells {
+		cellA := &(m.cells[i])
+		if !cellA.room || cellA.state.excluded() {
+			continue
+		}
+		// We only need to check the right and lower neighbors, since the
+		// other two were checked when visiting those cells.
+		for dir := 2; dir < 4; dir++ {
+			neighbor := m.neighborInDirection(i, dir)
+			if neighbor < 0 {
+				continue
+			}
+			cellB := &(m.cells[neighbor])
+			if !cellB.room || cellB.state.excluded() {
+				continue
+			}
+			m.setWall(i, dir, false)
+			cellA.djSet.union(cellB.djSet)
+			if m.observer != nil {
+				m.observer.WallRemoved(i, dir)
+			}
+		}
+	}
+}
+
 // Returns the index of the neighboring cell from the gridNeighborInfo isntance
 func (m *GridMaze) neighborIndex(n *gridNeighborInfo) (int, error) {
 	if n.neighborDirection == 2 {
@@ -578,8 +804,18 @@ func (m *GridMaze) RegenerateFromSeed(seed int64) error {
 		return fmt.Errorf("Error initializing maze state: %w", e)
 	}
 	m.randomSeed = seed
+	// Any keys and doors were placed for the previous layout.
+	m.doors = nil
+	m.keyCells = nil
+	m.hasOneWay = false
+	m.portals = nil
+	m.portalPairs = nil
 	rng := rand.New(rand.NewSource(seed))
+	if m.observer != nil {
+		m.observer.GenerationStarted(m)
+	}
 	startTime := time.Now()
+	m.openRooms()
 
 	for len(m.neighbors) != 0 {
 		// Pick a random pair of cells to connect.
@@ -606,6 +842,9 @@ func (m *GridMaze) RegenerateFromSeed(seed int64) error {
 		}
 		// Combine the sets containing the two cells.
 		cellA.djSet.union(cellB.djSet)
+		if m.observer != nil {
+			m.observer.WallRemoved(tmp.baseIndex, tmp.neighborDirection)
+		}
 	}
 
 	m.generationTime = time.Since(startTime).Seconds()
@@ -615,7 +854,7 @@ func (m *GridMaze) RegenerateFromSeed(seed int64) error {
 		m.startCellIndex = 0
 		m.endCellIndex = len(m.cells) - 1
 	}
-	return nil
+	return m.applyEndpointMode()
 }
 
 // Removes any walls that only touch one other, reducing overall noise in the
@@ -753,7 +992,8 @@ func setDirRanking(currentCol, currentRow, targetCol, targetRow int,
 // Used internally by ShowSolution.
 func (m *GridMaze) isReachableAndUnvisited(currentIndex, currentCol,
 	currentRow, moveDir int, visited []bool) (bool, int) {
-	if m.cells[currentIndex].walls[moveDir] {
+	c := &(m.cells[currentIndex])
+	if c.walls[moveDir] || c.noExit[moveDir] {
 		return false, -1
 	}
 	dstIndex := currentIndex
@@ -801,14 +1041,58 @@ func (m *GridMaze) clearSolution() error {
 }
 
 func (m *GridMaze) ShowSolution(show bool) error {
-	// We perform basically a depth-first search here, prioritizing moving in
-	// whichever direction has the shortest manhattan distance to the target.
 	if !show {
 		return m.clearSolution()
 	}
+	path, e := m.solveRoute(nil)
+	if e != nil {
+		return e
+	}
+	for _, index := range path {
+		m.cells[index].state = 1
+	}
+	return nil
+}
+
+// Returns the indices of every cell the maze's route must visit, in order:
+// the start cell, each waypoint, and then the end cell.
+func (m *GridMaze) routeStops() []int {
+	stops := make([]int, 0, len(m.waypoints)+2)
+	stops = append(stops, m.startCellIndex)
+	stops = append(stops, m.waypoints...)
+	stops = append(stops, m.endCellIndex)
+	return stops
+}
+
+// Finds a path from the start cell to the end cell that passes through each of
+// the maze's waypoints in order. The returned path may visit some cells more
+// than once. If trace is non-nil, every step of the search is recorded in it.
+func (m *GridMaze) solveRoute(trace *SolverTrace) ([]int, error) {
+	stops := m.routeStops()
+	route := make([]int, 0, 64)
+	route = append(route, m.startCellIndex)
+	for i := 1; i < len(stops); i++ {
+		leg, e := m.solvePath(stops[i-1], stops[i], trace)
+		if e != nil {
+			return nil, e
+		}
+		// Each leg starts at the end of the previous one.
+		route = append(route, leg[1:]...)
+	}
+	return route, nil
+}
+
+// Finds a path between the cells at the two indices, returning the indices of
+// every cell along the path, starting with startIndex and ending with
+// endIndex. If trace is non-nil, every step of the search is recorded in it.
+// Used internally by ShowSolution.
+func (m *GridMaze) solvePath(startIndex, endIndex int,
+	trace *SolverTrace) ([]int, error) {
+	// We perform basically a depth-first search here, prioritizing moving in
+	// whichever direction has the shortest manhattan distance to the target.
 	var endRow, endCol int
-	endCol = m.endCellIndex % m.width
-	endRow = m.endCellIndex / m.width
+	endCol = endIndex % m.width
+	endRow = endIndex / m.width
 	visited := make([]bool, len(m.cells))
 	// Mark "excluded" cells as visited, just so the solution path will never
 	// attempt to go through them.
@@ -827,8 +1111,9 @@ func (m *GridMaze) ShowSolution(show bool) error {
 	// The initial capacity of this is arbitrary, but hopefully something big
 	// enough that it won't need to be reallocated.
 	dfsStack := make([]int, 0, len(m.cells)/2)
-	dfsStack = append(dfsStack, m.startCellIndex)
-	visited[m.startCellIndex] = true
+	dfsStack = append(dfsStack, startIndex)
+	visited[startIndex] = true
+	trace.record(m, startIndex, SearchFrontier)
 
 	// Will be filled with some permutation of the values 0 through 3, (left,
 	// up, right, down), where index 0 in this array is the best direction to
@@ -840,10 +1125,14 @@ DFSLoop:
 	for {
 		// Select the next path starting-point from the top of the stack
 		if len(dfsStack) == 0 {
-			return fmt.Errorf("Internal error: failed to solve maze")
+			start := m.cellPoint(startIndex)
+			end := m.cellPoint(endIndex)
+			return nil, fmt.Errorf("Cell (%d, %d) can't be reached from "+
+				"cell (%d, %d)", end.X, end.Y, start.X, start.Y)
 		}
 		currentIndex := dfsStack[len(dfsStack)-1]
 		dfsStack = dfsStack[:len(dfsStack)-1]
+		trace.record(m, currentIndex, SearchVisited)
 		currentCol := currentIndex % m.width
 		currentRow := currentIndex / m.width
 		if (currentRow == endRow) && (currentCol == endCol) {
@@ -856,18 +1145,24 @@ DFSLoop:
 		for {
 			setDirRanking(currentCol, currentRow, endCol, endRow,
 				dirRanking[:])
-			moveDir := -1
 			moveDst := -1
-			for _, v := range dirRanking {
-				okMove, dstIndex := m.isReachableAndUnvisited(currentIndex,
-					currentCol, currentRow, v, visited)
+			for i := 0; i <= len(dirRanking); i++ {
+				var okMove bool
+				var dstIndex int
+				if i < len(dirRanking) {
+					okMove, dstIndex = m.isReachableAndUnvisited(currentIndex,
+						currentCol, currentRow, dirRanking[i], visited)
+				} else {
+					// Taking a portal is always the last option.
+					okMove, dstIndex = m.isPortalUnvisited(currentIndex,
+						visited)
+				}
 				if !okMove {
 					continue
 				}
 				if moveDst == -1 {
 					// We found the next step in our move
 					moveDst = dstIndex
-					moveDir = v
 					continue
 				}
 				// We've found a valid direction, but we already have chosen
@@ -875,39 +1170,39 @@ DFSLoop:
 				visited[dstIndex] = true
 				parentIndices[dstIndex] = currentIndex
 				dfsStack = append(dfsStack, dstIndex)
+				trace.record(m, dstIndex, SearchFrontier)
 			}
 			if moveDst < 0 {
 				// Can't make any more moves along this path.
+				trace.record(m, currentIndex, SearchDeadEnd)
 				break
 			}
 			// Move to the destination index
 			visited[moveDst] = true
+			trace.record(m, moveDst, SearchVisited)
 			parentIndices[moveDst] = currentIndex
 			currentIndex = moveDst
-			switch moveDir {
-			case 0:
-				currentCol--
-			case 1:
-				currentRow--
-			case 2:
-				currentCol++
-			case 3:
-				currentRow++
-			}
+			currentCol = currentIndex % m.width
+			currentRow = currentIndex / m.width
 			if (currentRow == endRow) && (currentCol == endCol) {
 				break DFSLoop
 			}
 		}
 	}
 
-	// Highlight the path by following the chain of parent indices from the end
-	index := m.endCellIndex
+	// Build the path by following the chain of parent indices from the end,
+	// and then reverse it so it starts at the start.
+	path := make([]int, 0, 64)
+	index := endIndex
 	for index >= 0 {
-		m.cells[index].state = 1
+		path = append(path, index)
 		index = parentIndices[index]
 	}
-
-	return nil
+	for i := 0; i < len(path)/2; i++ {
+		j := len(path) - 1 - i
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
 }
 
 // Used for processing either the start cell or end cell in the maze. If the
@@ -958,28 +1253,28 @@ func (m *GridMaze) processEndpointCell(cellIndex int) (image.Point, float32) {
 	// otherwise it would have been on the maze border.) Start with the left
 	// neighbor.
 	neighbor := m.cells[cellIndex-1]
-	if neighbor.state.excluded() {
+	if neighbor.state.outside() {
 		pt := image.Pt(cellPixels*col, rowMidPixel)
 		m.cells[cellIndex].walls[0] = false
 		return pt, 0.0
 	}
 	// Right neighbor
 	neighbor = m.cells[cellIndex+1]
-	if neighbor.state.excluded() {
+	if neighbor.state.outside() {
 		pt := image.Pt(cellPixels*(col+1)-1, rowMidPixel)
 		m.cells[cellIndex].walls[2] = false
 		return pt, 180.0
 	}
 	// Above neighbor
 	neighbor = m.cells[cellIndex-m.width]
-	if neighbor.state.excluded() {
+	if neighbor.state.outside() {
 		pt := image.Pt(colMidPixel, cellPixels*row)
 		m.cells[cellIndex].walls[1] = false
 		return pt, 270.0
 	}
 	// Below neighbor
 	neighbor = m.cells[cellIndex+m.width]
-	if neighbor.state.excluded() {
+	if neighbor.state.outside() {
 		pt := image.Pt(colMidPixel, cellPixels*(row+1)-1)
 		m.cells[cellIndex].walls[3] = false
 		return pt, 90.0
@@ -995,21 +1290,104 @@ func (m *GridMaze) GetInfo() *MazeInfo {
 		"%.03f seconds", m.width, m.height, m.randomSeed, m.generationTime)
 	startPt, startDir := m.processEndpointCell(m.startCellIndex)
 	endPt, endDir := m.processEndpointCell(m.endCellIndex)
-	if endDir >= 0 {
-		// Need to flip the endDir without making it negative.
-		if endDir >= 180.0 {
-			endDir -= 180.0
-		} else {
-			endDir += 180.0
-		}
-	}
-	return &MazeInfo{
+	endDir = flipEndpointAngle(endDir)
+	toReturn := &MazeInfo{
 		StartPoint: startPt,
 		StartAngle: startDir,
 		EndPoint:   endPt,
 		EndAngle:   endDir,
 		DebugInfo:  s,
 	}
+	for _, index := range m.allStarts() {
+		pt, angle := m.processEndpointCell(index)
+		toReturn.Starts = append(toReturn.Starts, EndpointInfo{
+			Cell:  m.cellPoint(index),
+			Point: pt,
+			Angle: angle,
+		})
+	}
+	for _, index := range m.allEnds() {
+		pt, angle := m.processEndpointCell(index)
+		toReturn.Ends = append(toReturn.Ends, EndpointInfo{
+			Cell:  m.cellPoint(index),
+			Point: pt,
+			Angle: flipEndpointAngle(angle),
+		})
+	}
+	return toReturn
+}
+
+// Reverses the direction of an arrow returned by processEndpointCell, so that
+// it points out of the maze. Negative angles are left unchanged.
+func flipEndpointAngle(angle float32) float32 {
+	if angle < 0 {
+		return angle
+	}
+	// Need to flip the angle without making it negative.
+	if angle >= 180.0 {
+		return angle - 180.0
+	}
+	return angle + 180.0
+}
+
+// Returns the index of the cell adjacent to the given cell in the given
+// direction (0 = left, 1 = up, 2 = right, 3 = down). Returns -1 if there is no
+// such cell because the given cell is on the maze's border.
+func (m *GridMaze) neighborInDirection(cellIndex, direction int) int {
+	col := cellIndex % m.width
+	row := cellIndex / m.width
+	switch direction {
+	case 0:
+		if col == 0 {
+			return -1
+		}
+		return cellIndex - 1
+	case 1:
+		if row == 0 {
+			return -1
+		}
+		return cellIndex - m.width
+	case 2:
+		if col == (m.width - 1) {
+			return -1
+		}
+		return cellIndex + 1
+	case 3:
+		if row == (m.height - 1) {
+			return -1
+		}
+		return cellIndex + m.width
+	}
+	return -1
+}
+
+// Sets or clears the wall in the given direction of the given cell, along with
+// the corresponding wall of the neighboring cell, if there is one. Either way,
+// the passage will no longer be one-way.
+func (m *GridMaze) setWall(cellIndex, direction int, present bool) {
+	m.cells[cellIndex].walls[direction] = present
+	m.cells[cellIndex].noExit[direction] = false
+	neighbor := m.neighborInDirection(cellIndex, direction)
+	if neighbor < 0 {
+		return
+	}
+	m.cells[neighbor].walls[(direction+2)%4] = present
+	m.cells[neighbor].noExit[(direction+2)%4] = false
+}
+
+// Returns the column and row of the cell at the given index.
+func (m *GridMaze) cellPoint(cellIndex int) image.Point {
+	return image.Pt(cellIndex%m.width, cellIndex/m.width)
+}
+
+// Returns the index of the cell at the given column and row, or an error if
+// the point is outside of the maze.
+func (m *GridMaze) cellIndex(p image.Point) (int, error) {
+	if (p.X < 0) || (p.Y < 0) || (p.X >= m.width) || (p.Y >= m.height) {
+		return -1, fmt.Errorf("Cell (%d, %d) is outside of the %dx%d maze",
+			p.X, p.Y, m.width, m.height)
+	}
+	return p.Y*m.width + p.X, nil
 }
 
 // Returns the cell at the row and column.
@@ -0,0 +1,207 @@
+package maze
+
+// This file contains functions for adding one-way passages to a GridMaze,
+// which can only be traversed in a single direction.
+
+import (
+	"fmt"
+	"image"
+	"math/rand"
+	"time"
+)
+
+// Makes the open passage in the given direction of the given cell one-way, so
+// that it can only be used to move from the cell in that direction. Returns an
+// error if there's a wall in the given direction, or if the direction leads
+// outside of the maze. Note that this may make the maze unsolvable; use
+// AddOneWayPassages to add one-way passages while keeping it solvable.
+func (m *GridMaze) SetOneWay(cell image.Point, direction int) error {
+	index, e := m.cellIndex(cell)
+	if e != nil {
+		return e
+	}
+	if (direction < 0) || (direction > 3) {
+		return fmt.Errorf("Invalid direction: %d", direction)
+	}
+	neighbor := m.neighborInDirection(index, direction)
+	if neighbor < 0 {
+		return fmt.Errorf("Direction %d from cell %s leads outside the maze",
+			direction, cell)
+	}
+	if m.cells[index].walls[direction] {
+		return fmt.Errorf("Cell %s has a wall in direction %d", cell,
+			direction)
+	}
+	m.setOneWay(index, direction)
+	return nil
+}
+
+// Makes the passage leaving the given cell in the given direction one-way,
+// without any error checking.
+func (m *GridMaze) setOneWay(cellIndex, direction int) {
+	neighbor := m.neighborInDirection(cellIndex, direction)
+	m.cells[cellIndex].noExit[direction] = false
+	m.cells[neighbor].noExit[(direction+2)%4] = true
+	m.hasOneWay = true
+}
+
+// Returns true if the passage in the given direction of the given cell is
+// open, but can only be traversed by moving from the cell in that direction.
+func (m *GridMaze) IsOneWay(cell image.Point, direction int) bool {
+	index, e := m.cellIndex(cell)
+	if (e != nil) || (direction < 0) || (direction > 3) {
+		return false
+	}
+	neighbor := m.neighborInDirection(index, direction)
+	if (neighbor < 0) || m.cells[index].walls[direction] {
+		return false
+	}
+	return m.cells[neighbor].noExit[(direction+2)%4]
+}
+
+// Makes every passage in the maze traversable in both directions again.
+func (m *GridMaze) ClearOneWayPassages() {
+	for i := range m.cells {
+		c := &(m.cells[i])
+		for j := range c.noExit {
+			c.noExit[j] = false
+		}
+	}
+	m.hasOneWay = false
+}
+
+// Returns true if every stop in the maze's route (the start, any waypoints,
+// then the end) can be reached from the previous one.
+func (m *GridMaze) routeReachable() bool {
+	stops := m.routeStops()
+	for i := 1; i < len(stops); i++ {
+		if m.distancesFrom(stops[i-1])[stops[i]] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Makes up to count randomly chosen passages one-way, picking a random
+// direction for each. If a direction would make it impossible to follow the
+// route from the start, through any waypoints, to the end, the opposite
+// direction is used instead. Passages the route needs in both directions are
+// left two-way, so the maze always remains solvable. Returns the number of
+// passages that were made one-way, which is smaller than count if the maze
+// doesn't have enough passages that can be made one-way. If the given seed is
+// not positive, a new seed will be selected based on the current time in
+// nanoseconds.
+func (m *GridMaze) AddOneWayPassages(count int, seed int64) (int, error) {
+	if !m.routeReachable() {
+		return 0, fmt.Errorf("The maze isn't solvable before adding " +
+			"one-way passages")
+	}
+	if seed <= 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	// Collect every two-way passage, identified by the cell above or to the
+	// left of it, along with a direction of 2 or 3.
+	var passages []wallRemoval
+	for i := range m.cells {
+		c := &(m.cells[i])
+		if c.state.excluded() {
+			continue
+		}
+		for dir := 2; dir <= 3; dir++ {
+			neighbor := m.neighborInDirection(i, dir)
+			if c.walls[dir] || (neighbor < 0) {
+				continue
+			}
+			if c.noExit[dir] || m.cells[neighbor].noExit[(dir+2)%4] {
+				continue
+			}
+			if m.cells[neighbor].state.excluded() {
+				continue
+			}
+			passages = append(passages, wallRemoval{
+				cellIndex: i,
+				direction: dir,
+			})
+		}
+	}
+	rng.Shuffle(len(passages), func(a, b int) {
+		passages[a], passages[b] = passages[b], passages[a]
+	})
+	added := 0
+	for _, p := range passages {
+		if added >= count {
+			break
+		}
+		index, dir := p.cellIndex, p.direction
+		if rng.Intn(2) == 0 {
+			index = m.neighborInDirection(index, dir)
+			dir = (dir + 2) % 4
+		}
+		m.setOneWay(index, dir)
+		if m.routeReachable() {
+			added++
+			continue
+		}
+		m.setOneWay(m.neighborInDirection(index, dir), (dir+2)%4)
+		if m.routeReachable() {
+			added++
+			continue
+		}
+		// The route uses the passage in both directions, e.g. to visit a
+		// waypoint at the end of a dead end, so leave it two-way.
+		m.clearOneWay(index, dir)
+	}
+	return added, nil
+}
+
+// Makes the passage in the given direction of the given cell two-way again,
+// without any error checking.
+func (m *GridMaze) clearOneWay(cellIndex, direction int) {
+	neighbor := m.neighborInDirection(cellIndex, direction)
+	m.cells[cellIndex].noExit[direction] = false
+	m.cells[neighbor].noExit[(direction+2)%4] = false
+}
+
+// Returns true if the given pixel of the cell is part of an arrowhead drawn
+// on a one-way passage out of the cell. The arrowhead points in the direction
+// the passage may be traversed, with its tip at the cell's edge.
+func (c *gridMazeCell) oneWayArrowAt(x, y int) bool {
+	m := c.parent
+	cellPixels := m.cellPixels
+	size := cellPixels / 4
+	if size < 1 {
+		return false
+	}
+	center := cellPixels / 2
+	last := cellPixels - 1
+	for dir := 0; dir < 4; dir++ {
+		if c.walls[dir] || c.noExit[dir] {
+			continue
+		}
+		neighbor := m.neighborInDirection(c.cellIndex, dir)
+		if (neighbor < 0) || !m.cells[neighbor].noExit[(dir+2)%4] {
+			continue
+		}
+		// Convert the pixel to its distance back from the arrow's tip, and
+		// its offset from the arrow's center line.
+		var back, offset int
+		switch dir {
+		case 0:
+			back, offset = x-1, y-center
+		case 1:
+			back, offset = y-1, x-center
+		case 2:
+			back, offset = (last-1)-x, y-center
+		case 3:
+			back, offset = (last-1)-y, x-center
+		}
+		if (back < 0) || (back >= size) {
+			continue
+		}
+		if absInt(offset) <= back {
+			return true
+		}
+	}
+	return false
+}
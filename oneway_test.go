@@ -0,0 +1,188 @@
+package maze
+
+import (
+	"image"
+	"testing"
+)
+
+// Returns the number of one-way passages in the maze.
+func countOneWay(m *GridMaze) int {
+	toReturn := 0
+	for i := range m.cells {
+		for dir := 0; dir < 4; dir++ {
+			if m.IsOneWay(m.cellPoint(i), dir) {
+				toReturn++
+			}
+		}
+	}
+	return toReturn
+}
+
+// Returns the index of a cell with exactly one passage, other than the start
+// or end, or -1 if there isn't one.
+func findDeadEnd(m *GridMaze) int {
+	for i := range m.cells {
+		if (i == m.startCellIndex) || (i == m.endCellIndex) {
+			continue
+		}
+		if len(m.passageNeighbors(i, nil)) == 1 {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestSetOneWay(t *testing.T) {
+	// A straight corridor from the start to the end.
+	m := buildMaze(t, 3, 1, [][2]image.Point{
+		{{0, 0}, {1, 0}},
+		{{1, 0}, {2, 0}},
+	})
+	e := m.SetOneWay(image.Pt(1, 0), 2)
+	if e != nil {
+		t.Fatalf("Failed making passage one-way: %s", e)
+	}
+	if !m.IsOneWay(image.Pt(1, 0), 2) {
+		t.Fatalf("The passage wasn't reported as one-way")
+	}
+	if m.IsOneWay(image.Pt(2, 0), 0) {
+		t.Fatalf("The passage was reported as one-way in both directions")
+	}
+	if !isLegalMove(m, 1, 2) || isLegalMove(m, 2, 1) {
+		t.Fatalf("Moves through the passage don't match its direction")
+	}
+	if !bruteForceRouteReachable(m) {
+		t.Fatalf("A one-way passage towards the end blocked the route")
+	}
+	e = m.SetOneWay(image.Pt(2, 0), 0)
+	if e != nil {
+		t.Fatalf("Failed reversing one-way passage: %s", e)
+	}
+	if m.IsOneWay(image.Pt(1, 0), 2) || !m.IsOneWay(image.Pt(2, 0), 0) {
+		t.Fatalf("Reversing the passage didn't change its direction")
+	}
+	m.ClearOneWayPassages()
+	if countOneWay(m) != 0 {
+		t.Fatalf("ClearOneWayPassages left one-way passages")
+	}
+	tests := []struct {
+		cell      image.Point
+		direction int
+	}{
+		{image.Pt(0, 0), 1},
+		{image.Pt(0, 0), 3},
+		{image.Pt(2, 0), 2},
+		{image.Pt(1, 0), 4},
+		{image.Pt(3, 0), 0},
+	}
+	for _, test := range tests {
+		if m.SetOneWay(test.cell, test.direction) == nil {
+			t.Fatalf("Didn't get an error making direction %d of cell %s "+
+				"one-way", test.direction, test.cell)
+		}
+	}
+}
+
+func TestAddOneWayPassagesCorridor(t *testing.T) {
+	// A corridor along the top row to the end at the bottom right, with a
+	// waypoint at the end of a two-cell dead end off of (1, 0), and a
+	// one-cell dead end off of (2, 0). The route uses the waypoint's dead
+	// end in both directions, so only the other five passages can be made
+	// one-way.
+	passages := [][2]image.Point{
+		{{0, 0}, {1, 0}},
+		{{1, 0}, {2, 0}},
+		{{2, 0}, {3, 0}},
+		{{3, 0}, {3, 1}},
+		{{1, 0}, {1, 1}},
+		{{1, 1}, {0, 1}},
+		{{2, 0}, {2, 1}},
+	}
+	for seed := int64(1); seed <= 20; seed++ {
+		m := buildMaze(t, 4, 2, passages)
+		e := m.SetCheckpoints([]image.Point{{0, 1}})
+		if e != nil {
+			t.Fatalf("Failed setting checkpoint: %s", e)
+		}
+		added, e := m.AddOneWayPassages(100, seed)
+		if e != nil {
+			t.Fatalf("Seed %d: failed adding one-way passages: %s", seed, e)
+		}
+		if (added != 5) || (countOneWay(m) != 5) {
+			t.Fatalf("Seed %d: added %d one-way passages, and the maze has "+
+				"%d, expected 5", seed, added, countOneWay(m))
+		}
+		for _, p := range passages[:4] {
+			a, _ := m.cellIndex(p[0])
+			b, _ := m.cellIndex(p[1])
+			if !m.IsOneWay(p[0], m.directionBetween(a, b)) {
+				t.Fatalf("Seed %d: passage from %s to %s doesn't lead "+
+					"towards the end", seed, p[0], p[1])
+			}
+		}
+		for _, p := range passages[4:6] {
+			a, _ := m.cellIndex(p[0])
+			b, _ := m.cellIndex(p[1])
+			if !isLegalMove(m, a, b) || !isLegalMove(m, b, a) {
+				t.Fatalf("Seed %d: passage from %s to %s isn't two-way", seed,
+					p[0], p[1])
+			}
+		}
+		if !bruteForceRouteReachable(m) {
+			t.Fatalf("Seed %d: the maze is no longer solvable", seed)
+		}
+	}
+}
+
+func TestAddOneWayPassagesSolvable(t *testing.T) {
+	tests := []struct {
+		name    string
+		erosion int
+		// If set, a dead end is made a waypoint, which the route must enter
+		// and leave through the same passage.
+		deadEndWaypoint bool
+	}{
+		{"perfect", 0, false},
+		{"braided", 3, false},
+		{"dead-end waypoint", 0, true},
+		{"braided dead-end waypoint", 1, true},
+	}
+	for _, test := range tests {
+		for seed := int64(1); seed <= 30; seed++ {
+			m := newTestMaze(t, 10, 10, seed, test.erosion)
+			if test.deadEndWaypoint {
+				deadEnd := findDeadEnd(m)
+				if deadEnd < 0 {
+					continue
+				}
+				e := m.SetCheckpoints([]image.Point{m.cellPoint(deadEnd)})
+				if e != nil {
+					t.Fatalf("Failed setting checkpoint: %s", e)
+				}
+			}
+			added, e := m.AddOneWayPassages(60, seed)
+			if e != nil {
+				t.Fatalf("%s maze, seed %d: failed adding one-way "+
+					"passages: %s", test.name, seed, e)
+			}
+			if added > 60 {
+				t.Fatalf("%s maze, seed %d: added %d passages, more than "+
+					"requested", test.name, seed, added)
+			}
+			if countOneWay(m) != added {
+				t.Fatalf("%s maze, seed %d: reported %d one-way passages, "+
+					"but the maze has %d", test.name, seed, added,
+					countOneWay(m))
+			}
+			if !bruteForceRouteReachable(m) {
+				t.Fatalf("%s maze, seed %d: the maze is no longer solvable",
+					test.name, seed)
+			}
+			_, e = m.TraceSolution()
+			if e != nil {
+				t.Fatalf("%s maze, seed %d: solver failed: %s", test.name,
+					seed, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package maze
+
+// This file contains functions for adding portals to a GridMaze. A portal
+// links two cells anywhere in the maze, and moving between them takes a
+// single step.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"time"
+)
+
+// The colors used to draw each pair of portals. Pair n uses color
+// n % len(portalColors).
+var portalColors = []color.Color{
+	color.RGBA{130, 50, 220, 255},
+	color.RGBA{0, 160, 200, 255},
+	color.RGBA{230, 80, 150, 255},
+	color.RGBA{90, 160, 20, 255},
+	color.RGBA{200, 120, 0, 255},
+	color.RGBA{60, 60, 200, 255},
+}
+
+// Returns the color used to draw the given pair of portals.
+func portalColor(pair int) color.Color {
+	return portalColors[pair%len(portalColors)]
+}
+
+// Returns the index of the cell linked to the given cell by a portal, or -1
+// if the cell isn't a portal.
+func (m *GridMaze) portalPartner(cellIndex int) int {
+	pair, ok := m.portals[cellIndex]
+	if !ok {
+		return -1
+	}
+	if m.portalPairs[pair][0] == cellIndex {
+		return m.portalPairs[pair][1]
+	}
+	return m.portalPairs[pair][0]
+}
+
+// Used internally by ShowSolution. Like isReachableAndUnvisited, but for
+// moving through a portal in the current cell.
+func (m *GridMaze) isPortalUnvisited(currentIndex int,
+	visited []bool) (bool, int) {
+	dstIndex := m.portalPartner(currentIndex)
+	if (dstIndex < 0) || visited[dstIndex] {
+		return false, -1
+	}
+	return true, dstIndex
+}
+
+// Returns true if a move from the cell at index a to the cell at index b
+// goes through an open passage, rather than a portal. Portals may link
+// adjacent cells, even if there's a wall between them.
+func (m *GridMaze) isPassageStep(a, b int) bool {
+	dir := m.directionBetween(a, b)
+	return (dir >= 0) && !m.cells[a].walls[dir]
+}
+
+// A pair of cells linked by a portal.
+type PortalPair struct {
+	A, B image.Point
+}
+
+// Links the two given cells with a portal, so that moving from either one to
+// the other takes a single step. Returns an error if the cells are the same,
+// if either is excluded, or if either is already a portal.
+func (m *GridMaze) AddPortal(a, b image.Point) error {
+	indexA, e := m.cellIndex(a)
+	if e != nil {
+		return e
+	}
+	indexB, e := m.cellIndex(b)
+	if e != nil {
+		return e
+	}
+	if indexA == indexB {
+		return fmt.Errorf("Can't link cell %s to itself", a)
+	}
+	for _, index := range []int{indexA, indexB} {
+		if m.cells[index].state.excluded() {
+			return fmt.Errorf("Cell %s is excluded", m.cellPoint(index))
+		}
+		if m.portalPartner(index) >= 0 {
+			return fmt.Errorf("Cell %s is already a portal",
+				m.cellPoint(index))
+		}
+	}
+	m.addPortal(indexA, indexB)
+	return nil
+}
+
+// Links the cells at the two indices, without any error checking.
+func (m *GridMaze) addPortal(a, b int) {
+	if m.portals == nil {
+		m.portals = make(map[int]int)
+	}
+	pair := len(m.portalPairs)
+	m.portalPairs = append(m.portalPairs, [2]int{a, b})
+	m.portals[a] = pair
+	m.portals[b] = pair
+}
+
+// Removes the most recently added portal pair.
+func (m *GridMaze) removeLastPortal() {
+	last := m.portalPairs[len(m.portalPairs)-1]
+	delete(m.portals, last[0])
+	delete(m.portals, last[1])
+	m.portalPairs = m.portalPairs[:len(m.portalPairs)-1]
+}
+
+// Returns every pair of linked portal cells, in the order they were added.
+func (m *GridMaze) Portals() []PortalPair {
+	toReturn := make([]PortalPair, len(m.portalPairs))
+	for i, pair := range m.portalPairs {
+		toReturn[i] = PortalPair{
+			A: m.cellPoint(pair[0]),
+			B: m.cellPoint(pair[1]),
+		}
+	}
+	return toReturn
+}
+
+// Removes every portal from the maze.
+func (m *GridMaze) ClearPortals() {
+	m.portals = nil
+	m.portalPairs = nil
+}
+
+// Returns true if the cell can be given a portal by PlacePortals.
+func (m *GridMaze) canPlacePortal(cellIndex int) bool {
+	if m.cells[cellIndex].state.excluded() {
+		return false
+	}
+	if (cellIndex == m.startCellIndex) || (cellIndex == m.endCellIndex) {
+		return false
+	}
+	return m.portalPartner(cellIndex) < 0
+}
+
+// Adds up to count portals in a way that lengthens the solution. For each
+// portal, a wall is added somewhere along the middle of the current solution,
+// cutting the start off from the end. The portal then links the cell farthest
+// from the start on the start's side of the new wall with the cell farthest
+// from the end on the end's side. Stops early, returning the number of
+// portals added, if there's nowhere to add another portal without making the
+// maze unsolvable. If the given seed is not positive, a new seed will be
+// selected based on the current time in nanoseconds.
+func (m *GridMaze) PlacePortals(count int, seed int64) (int, error) {
+	if !m.routeReachable() {
+		return 0, fmt.Errorf("The maze isn't solvable before adding portals")
+	}
+	if seed <= 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	added := 0
+	for added < count {
+		path := m.shortestPath(m.startCellIndex, m.endCellIndex)
+		// Only cut passages between adjacent cells in the middle half of the
+		// solution, so both sides have room for a portal.
+		var cuts []int
+		for i := len(path) / 4; i < (3 * len(path) / 4); i++ {
+			if m.isPassageStep(path[i], path[i+1]) {
+				cuts = append(cuts, i)
+			}
+		}
+		if len(cuts) == 0 {
+			break
+		}
+		cut := cuts[rng.Intn(len(cuts))]
+		dir := m.directionBetween(path[cut], path[cut+1])
+		// Setting the wall clears any one-way flags, so remember them in
+		// case the cut needs to be undone.
+		next := path[cut+1]
+		noExit := m.cells[path[cut]].noExit[dir]
+		nextNoExit := m.cells[next].noExit[(dir+2)%4]
+		m.setWall(path[cut], dir, true)
+		fromStart := m.distancesFrom(m.startCellIndex)
+		fromEnd := m.distancesFrom(m.endCellIndex)
+		a, b := -1, -1
+		for i := range m.cells {
+			if !m.canPlacePortal(i) {
+				continue
+			}
+			if fromStart[i] >= 0 {
+				if (a < 0) || (fromStart[i] > fromStart[a]) {
+					a = i
+				}
+			} else if fromEnd[i] >= 0 {
+				if (b < 0) || (fromEnd[i] > fromEnd[b]) {
+					b = i
+				}
+			}
+		}
+		if (a >= 0) && (b >= 0) {
+			m.addPortal(a, b)
+			if m.routeReachable() {
+				added++
+				continue
+			}
+			m.removeLastPortal()
+		}
+		// The cut left no usable cells on one side, or the new portal didn't
+		// restore the route (e.g. due to one-way passages), so undo it.
+		m.setWall(path[cut], dir, false)
+		m.cells[path[cut]].noExit[dir] = noExit
+		m.cells[next].noExit[(dir+2)%4] = nextNoExit
+		break
+	}
+	return added, nil
+}
+
+// Returns true if the given pixel of the cell is part of the ring drawn to
+// mark a portal.
+func (c *gridMazeCell) portalMarkerAt(x, y int) bool {
+	if c.parent.portalPartner(c.cellIndex) < 0 {
+		return false
+	}
+	cellPixels := c.parent.cellPixels
+	// Work in doubled coordinates so the ring is centered between pixels
+	// for even cell sizes.
+	dx := 2*x + 1 - cellPixels
+	dy := 2*y + 1 - cellPixels
+	distSquared := dx*dx + dy*dy
+	outer := (2 * cellPixels) / 3
+	inner := outer - cellPixels/3
+	if inner < 0 {
+		inner = 0
+	}
+	return (distSquared <= outer*outer) && (distSquared >= inner*inner)
+}
@@ -0,0 +1,124 @@
+package maze
+
+import (
+	"image"
+	"testing"
+)
+
+// Returns the number of open passages between adjacent cells in the maze.
+func countPassages(m *GridMaze) int {
+	toReturn := 0
+	for i := range m.cells {
+		toReturn += len(m.passageNeighbors(i, nil))
+	}
+	return toReturn / 2
+}
+
+// Returns a copy of the one-way flags of every cell in the maze.
+func copyNoExit(m *GridMaze) [][4]bool {
+	toReturn := make([][4]bool, len(m.cells))
+	for i := range m.cells {
+		toReturn[i] = m.cells[i].noExit
+	}
+	return toReturn
+}
+
+func TestAddPortal(t *testing.T) {
+	// A straight corridor, where a portal between its ends makes the start
+	// adjacent to the end.
+	m := buildMaze(t, 5, 1, [][2]image.Point{
+		{{0, 0}, {1, 0}},
+		{{1, 0}, {2, 0}},
+		{{2, 0}, {3, 0}},
+		{{3, 0}, {4, 0}},
+	})
+	e := m.AddPortal(image.Pt(0, 0), image.Pt(4, 0))
+	if e != nil {
+		t.Fatalf("Failed adding portal: %s", e)
+	}
+	if m.distancesFrom(m.startCellIndex)[m.endCellIndex] != 1 {
+		t.Fatalf("The portal didn't link the start to the end")
+	}
+	portals := m.Portals()
+	if (len(portals) != 1) || (portals[0].A != image.Pt(0, 0)) ||
+		(portals[0].B != image.Pt(4, 0)) {
+		t.Fatalf("Got portals %v, expected one from (0,0) to (4,0)", portals)
+	}
+	tests := []struct {
+		name string
+		a, b image.Point
+	}{
+		{"same cell", image.Pt(2, 0), image.Pt(2, 0)},
+		{"existing portal", image.Pt(4, 0), image.Pt(2, 0)},
+		{"outside the maze", image.Pt(2, 0), image.Pt(5, 0)},
+	}
+	for _, test := range tests {
+		if m.AddPortal(test.a, test.b) == nil {
+			t.Fatalf("Didn't get an error adding a portal to the %s",
+				test.name)
+		}
+	}
+	m.ClearPortals()
+	if (len(m.Portals()) != 0) ||
+		(m.distancesFrom(m.startCellIndex)[m.endCellIndex] != 4) {
+		t.Fatalf("ClearPortals didn't remove the portal")
+	}
+}
+
+func TestPlacePortals(t *testing.T) {
+	tests := []struct {
+		name    string
+		erosion int
+		oneWay  int
+	}{
+		{"perfect", 0, 0},
+		{"braided", 3, 0},
+		{"one-way", 0, 30},
+		{"braided one-way", 3, 60},
+	}
+	for _, test := range tests {
+		for seed := int64(1); seed <= 20; seed++ {
+			m := newTestMaze(t, 12, 12, seed, test.erosion)
+			if test.oneWay > 0 {
+				_, e := m.AddOneWayPassages(test.oneWay, seed)
+				if e != nil {
+					t.Fatalf("Failed adding one-way passages: %s", e)
+				}
+			}
+			passages := countPassages(m)
+			noExit := copyNoExit(m)
+			added, e := m.PlacePortals(10, seed)
+			if e != nil {
+				t.Fatalf("%s maze, seed %d: failed placing portals: %s",
+					test.name, seed, e)
+			}
+			if len(m.Portals()) != added {
+				t.Fatalf("%s maze, seed %d: reported %d portals, but the "+
+					"maze has %d", test.name, seed, added, len(m.Portals()))
+			}
+			// Each portal replaces exactly one passage, and every other
+			// passage keeps its one-way flags, even if a cut was undone.
+			if countPassages(m) != (passages - added) {
+				t.Fatalf("%s maze, seed %d: %d passages became %d after "+
+					"adding %d portals", test.name, seed, passages,
+					countPassages(m), added)
+			}
+			for i := range m.cells {
+				for dir := 0; dir < 4; dir++ {
+					if m.cells[i].walls[dir] {
+						continue
+					}
+					if m.cells[i].noExit[dir] != noExit[i][dir] {
+						t.Fatalf("%s maze, seed %d: the one-way flag in "+
+							"direction %d of cell %s changed", test.name,
+							seed, dir, m.cellPoint(i))
+					}
+				}
+			}
+			if !bruteForceRouteReachable(m) {
+				t.Fatalf("%s maze, seed %d: the maze is no longer solvable",
+					test.name, seed)
+			}
+		}
+	}
+}
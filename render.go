@@ -0,0 +1,54 @@
+package maze
+
+// This file contains helpers for drawing additional information on top of a
+// GridMaze's normal appearance.
+
+import (
+	"image"
+	"image/color"
+)
+
+// Satisfies the image.Image interface. Draws a GridMaze as usual, but replaces
+// the "floor" of each cell (white, or the color of the cell's terrain) with a
+// color from the colors slice, if one is given for that cell. Walls and any
+// markers drawn in the cell are never covered by the overlay colors.
+type cellOverlay struct {
+	m *GridMaze
+	// Contains one entry per cell in m; nil entries are drawn normally.
+	colors []color.Color
+}
+
+// Returns a new overlay for the maze, with no cell colors set.
+func newCellOverlay(m *GridMaze) *cellOverlay {
+	return &cellOverlay{
+		m:      m,
+		colors: make([]color.Color, len(m.cells)),
+	}
+}
+
+func (o *cellOverlay) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (o *cellOverlay) Bounds() image.Rectangle {
+	return o.m.Bounds()
+}
+
+func (o *cellOverlay) At(x, y int) color.Color {
+	base := o.m.At(x, y)
+	if !(image.Point{x, y}).In(o.m.Bounds()) {
+		return base
+	}
+	cellPixels := o.m.cellPixels
+	cellIndex := (y/cellPixels)*o.m.width + (x / cellPixels)
+	c := o.colors[cellIndex]
+	if c == nil {
+		return base
+	}
+	r, g, b, _ := base.RGBA()
+	floorR, floorG, floorB, _ := o.m.cells[cellIndex].terrain.color().RGBA()
+	if (r != floorR) || (g != floorG) || (b != floorB) {
+		return base
+	}
+	return c
+}
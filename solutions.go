@@ -0,0 +1,163 @@
+package maze
+
+// This file contains functions for finding more than one solution to mazes
+// with loops, such as those produced by ErodeWalls.
+
+import (
+	"fmt"
+	"image"
+)
+
+// Counts the distinct simple paths (paths that never visit a cell twice) from
+// the start to the end, stopping once limit paths have been found. Returns
+// limit if there are at least that many paths. Waypoints are ignored. In a
+// maze without loops, this is always 1. The time taken can grow exponentially
+// with the number of loops, so use a modest limit for large braid mazes.
+func (m *GridMaze) CountSolutions(limit int) (int, error) {
+	if limit < 1 {
+		return 0, fmt.Errorf("Invalid solution limit: %d", limit)
+	}
+	if m.distancesFrom(m.startCellIndex)[m.endCellIndex] < 0 {
+		return 0, nil
+	}
+	visited := make([]bool, len(m.cells))
+	// Reuse a neighbor slice for each depth of the search, so the search
+	// doesn't allocate at every step.
+	var neighborBuffers [][]int
+	count := 0
+	var search func(current, depth int)
+	search = func(current, depth int) {
+		if current == m.endCellIndex {
+			count++
+			return
+		}
+		if depth >= len(neighborBuffers) {
+			neighborBuffers = append(neighborBuffers, make([]int, 0, 5))
+		}
+		neighbors := m.openNeighbors(current, neighborBuffers[depth][:0])
+		neighborBuffers[depth] = neighbors
+		visited[current] = true
+		for _, n := range neighbors {
+			if count >= limit {
+				break
+			}
+			if !visited[n] {
+				search(n, depth+1)
+			}
+		}
+		visited[current] = false
+	}
+	search(m.startCellIndex, 0)
+	return count, nil
+}
+
+// Returns true if the two lists of cell indices are identical.
+func sameCells(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if any of the paths is identical to p.
+func containsPath(paths [][]int, p []int) bool {
+	for _, other := range paths {
+		if sameCells(other, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// A single move between two cells, used to block moves in KShortestSolutions.
+type cellMove struct {
+	from, to int
+}
+
+// Returns up to k distinct simple paths from the start to the end, with the
+// fewest moves, in order of increasing length, using Yen's algorithm. Fewer
+// than k paths are returned if the maze doesn't have that many. Waypoints are
+// ignored. Returns an error if the end can't be reached from the start.
+func (m *GridMaze) KShortestSolutions(k int) ([][]image.Point, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("Invalid number of solutions: %d", k)
+	}
+	first := m.shortestPath(m.startCellIndex, m.endCellIndex)
+	if first == nil {
+		start := m.cellPoint(m.startCellIndex)
+		end := m.cellPoint(m.endCellIndex)
+		return nil, fmt.Errorf("Cell (%d, %d) can't be reached from "+
+			"cell (%d, %d)", end.X, end.Y, start.X, start.Y)
+	}
+	found := [][]int{first}
+	var candidates [][]int
+	blockedCells := make([]bool, len(m.cells))
+	blockedMoves := make(map[cellMove]bool)
+	canMove := func(from, to int) bool {
+		return !blockedCells[to] && !blockedMoves[cellMove{from, to}]
+	}
+	for len(found) < k {
+		previous := found[len(found)-1]
+		// Try branching off of the previous path at each of its cells.
+		for i := 0; i < (len(previous) - 1); i++ {
+			spur := previous[i]
+			root := previous[:i+1]
+			// Don't reuse the next move of any path sharing this root, so
+			// the new path is different.
+			for _, p := range found {
+				if (len(p) > (i + 1)) && sameCells(p[:i+1], root) {
+					blockedMoves[cellMove{p[i], p[i+1]}] = true
+				}
+			}
+			// Keep the path simple by not revisiting the root.
+			for _, index := range root[:i] {
+				blockedCells[index] = true
+			}
+			distances, parents := m.breadthFirstSearch(spur, canMove)
+			spurPath := pathFromParents(distances, parents, m.endCellIndex)
+			for _, index := range root[:i] {
+				blockedCells[index] = false
+			}
+			for move := range blockedMoves {
+				delete(blockedMoves, move)
+			}
+			if spurPath == nil {
+				continue
+			}
+			candidate := make([]int, 0, i+len(spurPath))
+			candidate = append(candidate, root[:i]...)
+			candidate = append(candidate, spurPath...)
+			if containsPath(found, candidate) ||
+				containsPath(candidates, candidate) {
+				continue
+			}
+			candidates = append(candidates, candidate)
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		// Move the shortest candidate to the list of found paths, breaking
+		// ties by whichever was found first.
+		best := 0
+		for i, c := range candidates {
+			if len(c) < len(candidates[best]) {
+				best = i
+			}
+		}
+		found = append(found, candidates[best])
+		candidates = append(candidates[:best], candidates[best+1:]...)
+	}
+	toReturn := make([][]image.Point, len(found))
+	for i, path := range found {
+		toReturn[i] = make([]image.Point, len(path))
+		for j, index := range path {
+			toReturn[i][j] = m.cellPoint(index)
+		}
+	}
+	return toReturn, nil
+}
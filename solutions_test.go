@@ -0,0 +1,182 @@
+package maze
+
+import (
+	"image"
+	"sort"
+	"testing"
+)
+
+// Returns the number of moves in every simple path from the start to the
+// end, found by exhaustive search, sorted from shortest to longest.
+func allSolutionLengths(m *GridMaze) []int {
+	var lengths []int
+	visited := make([]bool, len(m.cells))
+	var search func(current, moves int)
+	search = func(current, moves int) {
+		if current == m.endCellIndex {
+			lengths = append(lengths, moves)
+			return
+		}
+		visited[current] = true
+		for _, n := range m.openNeighbors(current, nil) {
+			if !visited[n] {
+				search(n, moves+1)
+			}
+		}
+		visited[current] = false
+	}
+	search(m.startCellIndex, 0)
+	sort.Ints(lengths)
+	return lengths
+}
+
+func TestCountSolutions(t *testing.T) {
+	tests := []struct {
+		name    string
+		erosion int
+	}{
+		{"perfect", 0},
+		{"braided", 2},
+		{"heavily braided", 3},
+	}
+	for _, test := range tests {
+		for seed := int64(1); seed <= 10; seed++ {
+			m := newTestMaze(t, 5, 5, seed, test.erosion)
+			expected := len(allSolutionLengths(m))
+			if (test.erosion == 0) && (expected != 1) {
+				t.Fatalf("Seed %d: perfect maze has %d solutions", seed,
+					expected)
+			}
+			count, e := m.CountSolutions(1000000)
+			if e != nil {
+				t.Fatalf("Failed counting solutions: %s", e)
+			}
+			if count != expected {
+				t.Fatalf("%s maze, seed %d: counted %d solutions, "+
+					"expected %d", test.name, seed, count, expected)
+			}
+			limit := (expected + 1) / 2
+			count, _ = m.CountSolutions(limit)
+			if count != limit {
+				t.Fatalf("%s maze, seed %d: got %d solutions with a limit "+
+					"of %d", test.name, seed, count, limit)
+			}
+		}
+	}
+	m := newTestMaze(t, 4, 4, 1, 0)
+	_, e := m.CountSolutions(0)
+	if e == nil {
+		t.Fatalf("Didn't get an error using a limit of 0")
+	}
+}
+
+// Returns a 3x3 maze without any interior walls.
+func openGridMaze(t *testing.T) *GridMaze {
+	var passages [][2]image.Point
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			if x < 2 {
+				passages = append(passages, [2]image.Point{{x, y},
+					{x + 1, y}})
+			}
+			if y < 2 {
+				passages = append(passages, [2]image.Point{{x, y},
+					{x, y + 1}})
+			}
+		}
+	}
+	return buildMaze(t, 3, 3, passages)
+}
+
+func TestSolutionsOpenGrid(t *testing.T) {
+	// There are 12 simple paths between opposite corners of a 3x3 grid: 6
+	// with 4 moves, 4 with 6 moves, and 2 with 8 moves.
+	m := openGridMaze(t)
+	count, e := m.CountSolutions(100)
+	if e != nil {
+		t.Fatalf("Failed counting solutions: %s", e)
+	}
+	if count != 12 {
+		t.Fatalf("Counted %d solutions, expected 12", count)
+	}
+	paths, e := m.KShortestSolutions(20)
+	if e != nil {
+		t.Fatalf("Failed finding solutions: %s", e)
+	}
+	expected := []int{4, 4, 4, 4, 4, 4, 6, 6, 6, 6, 8, 8}
+	if len(paths) != len(expected) {
+		t.Fatalf("Got %d solutions, expected %d", len(paths), len(expected))
+	}
+	for i, moves := range expected {
+		if (len(paths[i]) - 1) != moves {
+			t.Fatalf("Solution %d has %d moves, expected %d", i,
+				len(paths[i])-1, moves)
+		}
+	}
+}
+
+// Returns true if the path never visits the same cell twice.
+func isSimplePath(path []image.Point) bool {
+	seen := make(map[image.Point]bool)
+	for _, p := range path {
+		if seen[p] {
+			return false
+		}
+		seen[p] = true
+	}
+	return true
+}
+
+func TestKShortestSolutions(t *testing.T) {
+	const k = 8
+	for seed := int64(1); seed <= 10; seed++ {
+		m := newTestMaze(t, 5, 5, seed, 3)
+		lengths := allSolutionLengths(m)
+		paths, e := m.KShortestSolutions(k)
+		if e != nil {
+			t.Fatalf("Seed %d: failed finding solutions: %s", seed, e)
+		}
+		expectedCount := k
+		if len(lengths) < k {
+			expectedCount = len(lengths)
+		}
+		if len(paths) != expectedCount {
+			t.Fatalf("Seed %d: got %d solutions, expected %d", seed,
+				len(paths), expectedCount)
+		}
+		shortest := m.shortestPath(m.startCellIndex, m.endCellIndex)
+		if len(paths[0]) != len(shortest) {
+			t.Fatalf("Seed %d: first solution has %d cells, the shortest "+
+				"path has %d", seed, len(paths[0]), len(shortest))
+		}
+		for i, path := range paths {
+			checkWalk(t, m, path, m.StartCell(), m.EndCell())
+			if !isSimplePath(path) {
+				t.Fatalf("Seed %d: solution %d revisits a cell", seed, i)
+			}
+			if (len(path) - 1) != lengths[i] {
+				t.Fatalf("Seed %d: solution %d has %d moves, expected %d",
+					seed, i, len(path)-1, lengths[i])
+			}
+			for j := 0; j < i; j++ {
+				if pointsEqual(paths[j], path) {
+					t.Fatalf("Seed %d: solutions %d and %d are the same",
+						seed, j, i)
+				}
+			}
+		}
+	}
+}
+
+// Returns true if the two lists of points are identical.
+func pointsEqual(a, b []image.Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
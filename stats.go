@@ -0,0 +1,190 @@
+package maze
+
+// This file contains functions for computing metrics that describe the
+// structure and difficulty of a GridMaze.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Holds objective metrics about a maze's layout. Obtain using GridMaze.Stats.
+// Unless noted, "passages" refers to open connections between two cells that
+// are both part of the maze.
+type MazeStats struct {
+	// The number of cells that aren't excluded from the maze.
+	CellCount int
+	// The number of cells with exactly one passage.
+	DeadEnds int
+	// DegreeCounts[n] is the number of cells with exactly n passages. Cells
+	// with three or four passages are junctions.
+	DegreeCounts [5]int
+	// Maps corridor lengths to the number of corridors with that length. A
+	// corridor is a maximal chain of adjacent cells that each have exactly
+	// two passages, and its length is the number of cells in the chain.
+	CorridorLengths map[int]int
+	// The number of moves on the shortest path from the start to the end.
+	// Will be -1 if the end can't be reached from the start.
+	SolutionLength int
+	// The number of cells on the shortest solution path where someone
+	// following the path must choose between multiple unexplored passages.
+	DecisionPoints int
+	// The manhattan distance between the start and end cells.
+	ManhattanDistance int
+	// SolutionLength divided by ManhattanDistance. Larger values indicate a
+	// more roundabout solution. Zero if the ratio is undefined.
+	SolutionRatio float64
+	// The fraction of cells that lie within corridors (i.e. have exactly two
+	// passages). Mazes with a high "river" factor have long, winding
+	// passages with few short dead ends.
+	RiverFactor float64
+	// The number of independent loops in the maze: passages - cells +
+	// connected regions. Zero for a perfect maze.
+	Loops int
+}
+
+// Returns a multi-line, human-readable description of the stats.
+func (s *MazeStats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Cells: %d\n", s.CellCount)
+	fmt.Fprintf(&b, "Dead ends: %d\n", s.DeadEnds)
+	fmt.Fprintf(&b, "Cells by number of passages: ")
+	for i, count := range s.DegreeCounts {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%d: %d", i, count)
+	}
+	b.WriteString("\n")
+	lengths := make([]int, 0, len(s.CorridorLengths))
+	for length := range s.CorridorLengths {
+		lengths = append(lengths, length)
+	}
+	sort.Ints(lengths)
+	b.WriteString("Corridors by length: ")
+	for i, length := range lengths {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%d: %d", length, s.CorridorLengths[length])
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Solution length: %d (manhattan distance %d, ratio "+
+		"%.03f)\n", s.SolutionLength, s.ManhattanDistance, s.SolutionRatio)
+	fmt.Fprintf(&b, "Decision points on solution: %d\n", s.DecisionPoints)
+	fmt.Fprintf(&b, "River factor: %.03f\n", s.RiverFactor)
+	fmt.Fprintf(&b, "Loops: %d", s.Loops)
+	return b.String()
+}
+
+// Computes metrics describing the maze's current layout, including any
+// changes made after generation, e.g. by ErodeWalls.
+func (m *GridMaze) Stats() (*MazeStats, error) {
+	toReturn := &MazeStats{
+		CorridorLengths: make(map[int]int),
+	}
+	degrees := make([]int, len(m.cells))
+	var neighbors []int
+	passages := 0
+	for i := range m.cells {
+		if m.cells[i].state.excluded() {
+			continue
+		}
+		toReturn.CellCount++
+		neighbors = m.passageNeighbors(i, neighbors[:0])
+		degrees[i] = len(neighbors)
+		if len(neighbors) < len(toReturn.DegreeCounts) {
+			toReturn.DegreeCounts[len(neighbors)]++
+		}
+		passages += len(neighbors)
+	}
+	// Each passage was counted from both of the cells it connects.
+	passages /= 2
+	toReturn.DeadEnds = toReturn.DegreeCounts[1]
+	if toReturn.CellCount != 0 {
+		toReturn.RiverFactor = float64(toReturn.DegreeCounts[2]) /
+			float64(toReturn.CellCount)
+	}
+
+	// Find corridors by flood-filling chains of cells with two passages.
+	inCorridor := make([]bool, len(m.cells))
+	stack := make([]int, 0, 64)
+	for i := range m.cells {
+		if (degrees[i] != 2) || inCorridor[i] || m.cells[i].state.excluded() {
+			continue
+		}
+		length := 0
+		inCorridor[i] = true
+		stack = append(stack[:0], i)
+		for len(stack) != 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			length++
+			neighbors = m.passageNeighbors(current, neighbors[:0])
+			for _, n := range neighbors {
+				if (degrees[n] != 2) || inCorridor[n] {
+					continue
+				}
+				inCorridor[n] = true
+				stack = append(stack, n)
+			}
+		}
+		toReturn.CorridorLengths[length]++
+	}
+
+	// Count connected regions in order to count the loops.
+	regions := 0
+	reached := make([]bool, len(m.cells))
+	for i := range m.cells {
+		if reached[i] || m.cells[i].state.excluded() {
+			continue
+		}
+		regions++
+		reached[i] = true
+		stack = append(stack[:0], i)
+		for len(stack) != 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			neighbors = m.passageNeighbors(current, neighbors[:0])
+			for _, n := range neighbors {
+				if reached[n] {
+					continue
+				}
+				reached[n] = true
+				stack = append(stack, n)
+			}
+		}
+	}
+	toReturn.Loops = passages - toReturn.CellCount + regions
+
+	start := m.cellPoint(m.startCellIndex)
+	end := m.cellPoint(m.endCellIndex)
+	colDiff := end.X - start.X
+	if colDiff < 0 {
+		colDiff = -colDiff
+	}
+	rowDiff := end.Y - start.Y
+	if rowDiff < 0 {
+		rowDiff = -rowDiff
+	}
+	toReturn.ManhattanDistance = colDiff + rowDiff
+	path := m.shortestPath(m.startCellIndex, m.endCellIndex)
+	toReturn.SolutionLength = len(path) - 1
+	if (toReturn.SolutionLength >= 0) && (toReturn.ManhattanDistance != 0) {
+		toReturn.SolutionRatio = float64(toReturn.SolutionLength) /
+			float64(toReturn.ManhattanDistance)
+	}
+	for i := 0; i < (len(path) - 1); i++ {
+		// Every cell other than the start was entered through one of its
+		// passages, which doesn't count as a choice.
+		choices := degrees[path[i]]
+		if i != 0 {
+			choices--
+		}
+		if choices >= 2 {
+			toReturn.DecisionPoints++
+		}
+	}
+	return toReturn, nil
+}
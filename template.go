@@ -0,0 +1,539 @@
+package maze
+
+// This file contains the TemplateMask type, which describes the layout of a
+// maze before it's generated, along with functions for building masks from
+// images.
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Holds one TemplateCellType per cell of a maze that hasn't been generated
+// yet. Pass a TemplateMask to NewGridMazeFromMask to generate a maze.
+type TemplateMask struct {
+	width  int
+	height int
+	cells  []TemplateCellType
+}
+
+// Returns a new template mask with the given size in cells, in which every
+// cell is TemplateNormal.
+func NewTemplateMask(width, height int) (*TemplateMask, error) {
+	if (width < 1) || (height < 1) {
+		return nil, fmt.Errorf("width and height must be at least 1")
+	}
+	cellCount := width * height
+	if (cellCount <= 0) || ((cellCount / width) != height) {
+		return nil, fmt.Errorf("The template's size was too big")
+	}
+	return &TemplateMask{
+		width:  width,
+		height: height,
+		cells:  make([]TemplateCellType, cellCount),
+	}, nil
+}
+
+// Returns the width of the mask, in cells.
+func (t *TemplateMask) Width() int {
+	return t.width
+}
+
+// Returns the height of the mask, in cells.
+func (t *TemplateMask) Height() int {
+	return t.height
+}
+
+// Returns the type of the cell at the given column and row. Returns
+// TemplateExcluded for cells outside of the mask.
+func (t *TemplateMask) At(col, row int) TemplateCellType {
+	if (col < 0) || (row < 0) || (col >= t.width) || (row >= t.height) {
+		return TemplateExcluded
+	}
+	return t.cells[row*t.width+col]
+}
+
+// Sets the type of the cell at the given column and row. Does nothing if the
+// cell is outside of the mask.
+func (t *TemplateMask) Set(col, row int, cellType TemplateCellType) {
+	if (col < 0) || (row < 0) || (col >= t.width) || (row >= t.height) {
+		return
+	}
+	t.cells[row*t.width+col] = cellType
+}
+
+// Maps a single color in a template image to a type of cell.
+type TemplatePaletteEntry struct {
+	Color color.Color
+	Type  TemplateCellType
+	// The largest difference, in each of the red, green, and blue channels,
+	// between a pixel and Color for the pixel to match this entry. Channels
+	// range from 0 to 255.
+	Tolerance uint8
+}
+
+// Determines how colors in a template image are converted to types of cells.
+// Useful for templates that don't exactly follow the standard colors described
+// in the comment on NewGridMazeFromTemplate, e.g. anti-aliased or
+// JPEG-compressed images.
+type TemplatePalette struct {
+	// If a pixel matches multiple entries, the entry with the nearest color
+	// is used.
+	Entries []TemplatePaletteEntry
+	// If true, pixels that don't match any entry within its tolerance are
+	// treated as the entry with the nearest color, rather than causing an
+	// error.
+	Nearest bool
+}
+
+// Returns a palette containing the standard template colors, with a tolerance
+// that accommodates mild noise or anti-aliasing.
+func DefaultTemplatePalette() *TemplatePalette {
+	tolerance := uint8(48)
+	return &TemplatePalette{
+		Entries: []TemplatePaletteEntry{
+			{color.White, TemplateNormal, tolerance},
+			{color.Black, TemplateExcluded, tolerance},
+			{color.RGBA{0, 255, 0, 255}, TemplateStart, tolerance},
+			{color.RGBA{255, 0, 0, 255}, TemplateEnd, tolerance},
+			{color.RGBA{0, 0, 255, 255}, TemplateWaypoint, tolerance},
+			{color.RGBA{128, 128, 128, 255}, TemplateRoom, tolerance},
+			{color.RGBA{255, 255, 0, 255}, TemplateWall, tolerance},
+			{color.RGBA{150, 70, 0, 255}, TemplateMud, tolerance},
+			{color.RGBA{0, 255, 255, 255}, TemplateWater, tolerance},
+			{color.RGBA{255, 0, 255, 255}, TemplateStairs, tolerance},
+		},
+	}
+}
+
+// Describes how a pixel was matched to a palette entry.
+type paletteMatch uint8
+
+const (
+	// The pixel exactly matched an entry's color.
+	paletteExact paletteMatch = iota
+	// The pixel was within an entry's tolerance.
+	paletteTolerance
+	// The pixel was matched to the nearest entry, because the palette's
+	// Nearest field was set.
+	paletteNearest
+	// The pixel didn't match any entry.
+	paletteUnmatched
+)
+
+// Converts a color to 8-bit red, green, and blue channels.
+func rgb8(c color.Color) (int, int, int) {
+	r, g, b, _ := c.RGBA()
+	return int(r >> 8), int(g >> 8), int(b >> 8)
+}
+
+// Returns the absolute value of x.
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Returns the type of cell the color maps to, along with how it was matched.
+func (p *TemplatePalette) classify(c color.Color) (TemplateCellType,
+	paletteMatch) {
+	r, g, b := rgb8(c)
+	bestIndex := -1
+	bestDistance := 0
+	bestInTolerance := false
+	for i := range p.Entries {
+		entry := &(p.Entries[i])
+		er, eg, eb := rgb8(entry.Color)
+		dr := absInt(r - er)
+		dg := absInt(g - eg)
+		db := absInt(b - eb)
+		distance := dr*dr + dg*dg + db*db
+		tolerance := int(entry.Tolerance)
+		inTolerance := (dr <= tolerance) && (dg <= tolerance) &&
+			(db <= tolerance)
+		// Entries within their tolerance always take priority over entries
+		// that are merely nearby.
+		if (bestIndex >= 0) && (bestInTolerance && !inTolerance) {
+			continue
+		}
+		if (bestIndex >= 0) && (bestInTolerance == inTolerance) &&
+			(distance >= bestDistance) {
+			continue
+		}
+		bestIndex = i
+		bestDistance = distance
+		bestInTolerance = inTolerance
+	}
+	if bestIndex < 0 {
+		return TemplateNormal, paletteUnmatched
+	}
+	cellType := p.Entries[bestIndex].Type
+	if bestInTolerance {
+		if bestDistance == 0 {
+			return cellType, paletteExact
+		}
+		return cellType, paletteTolerance
+	}
+	if p.Nearest {
+		return cellType, paletteNearest
+	}
+	return TemplateNormal, paletteUnmatched
+}
+
+// Returns the type of cell the given color maps to, or an error if it doesn't
+// match any entry in the palette.
+func (p *TemplatePalette) Classify(c color.Color) (TemplateCellType, error) {
+	cellType, match := p.classify(c)
+	if match == paletteUnmatched {
+		r, g, b := rgb8(c)
+		return TemplateNormal, fmt.Errorf("Template color (RGB = %d, %d, %d) "+
+			"doesn't match any palette entry", r, g, b)
+	}
+	return cellType, nil
+}
+
+// Describes how well a template image matches a palette. Obtain using
+// TemplatePalette.Validate.
+type TemplateReport struct {
+	// The number of pixels that were mapped to each type of cell.
+	Counts map[TemplateCellType]int
+	// The number of pixels that exactly matched a palette color.
+	Exact int
+	// The number of pixels that were within an entry's tolerance, but didn't
+	// match its color exactly.
+	WithinTolerance int
+	// The number of pixels that were only mapped to a cell type because the
+	// palette's Nearest field was set.
+	NearestMatches int
+	// The coordinates of every pixel that didn't match any palette entry.
+	Unmatched []image.Point
+}
+
+// Returns a multi-line, human-readable summary of the report.
+func (r *TemplateReport) String() string {
+	var b strings.Builder
+	types := make([]int, 0, len(r.Counts))
+	for t := range r.Counts {
+		types = append(types, int(t))
+	}
+	sort.Ints(types)
+	b.WriteString("Cells by type: ")
+	for i, t := range types {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s: %d", TemplateCellType(t),
+			r.Counts[TemplateCellType(t)])
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Exact matches: %d\n", r.Exact)
+	fmt.Fprintf(&b, "Matches within tolerance: %d\n", r.WithinTolerance)
+	fmt.Fprintf(&b, "Nearest-color matches: %d\n", r.NearestMatches)
+	fmt.Fprintf(&b, "Unmatched pixels: %d", len(r.Unmatched))
+	if len(r.Unmatched) != 0 {
+		p := r.Unmatched[0]
+		fmt.Fprintf(&b, " (first at %d, %d)", p.X, p.Y)
+	}
+	return b.String()
+}
+
+// Checks every pixel in the given template image against the palette,
+// without building a template mask.
+func (p *TemplatePalette) Validate(pic image.Image) *TemplateReport {
+	toReturn := &TemplateReport{
+		Counts: make(map[TemplateCellType]int),
+	}
+	bounds := pic.Bounds().Canon()
+	for row := bounds.Min.Y; row < bounds.Max.Y; row++ {
+		for col := bounds.Min.X; col < bounds.Max.X; col++ {
+			cellType, match := p.classify(pic.At(col, row))
+			switch match {
+			case paletteExact:
+				toReturn.Exact++
+			case paletteTolerance:
+				toReturn.WithinTolerance++
+			case paletteNearest:
+				toReturn.NearestMatches++
+			case paletteUnmatched:
+				toReturn.Unmatched = append(toReturn.Unmatched,
+					image.Pt(col, row))
+				continue
+			}
+			toReturn.Counts[cellType]++
+		}
+	}
+	return toReturn
+}
+
+// Converts a template image to a template mask, with one cell per pixel. If
+// the palette is nil, the image must use the standard colors described in the
+// comment on NewGridMazeFromTemplate. Returns an error if any pixel doesn't
+// map to a type of cell.
+func TemplateMaskFromImage(pic image.Image,
+	palette *TemplatePalette) (*TemplateMask, error) {
+	bounds := pic.Bounds().Canon()
+	toReturn, e := NewTemplateMask(bounds.Dx(), bounds.Dy())
+	if e != nil {
+		return nil, e
+	}
+	classify := colorToTemplateCellType
+	if palette != nil {
+		classify = palette.Classify
+	}
+	cellIndex := -1
+	for row := bounds.Min.Y; row < bounds.Max.Y; row++ {
+		for col := bounds.Min.X; col < bounds.Max.X; col++ {
+			cellIndex++
+			cellType, e := classify(pic.At(col, row))
+			if e != nil {
+				return nil, fmt.Errorf("Bad template pixel at (%d, %d): %w",
+					col, row, e)
+			}
+			toReturn.cells[cellIndex] = cellType
+		}
+	}
+	return toReturn, nil
+}
+
+// Returns a palette that splits any image into normal and excluded cells
+// based on brightness alone, for use with DownsampleTemplate when turning
+// logos or silhouettes into mazes. Light pixels become normal cells and dark
+// pixels are excluded, unless invert is true, in which case the maze will be
+// shaped like the dark parts of the image.
+func SilhouettePalette(invert bool) *TemplatePalette {
+	light, dark := TemplateNormal, TemplateExcluded
+	if invert {
+		light, dark = dark, light
+	}
+	return &TemplatePalette{
+		Entries: []TemplatePaletteEntry{
+			{color.White, light, 127},
+			{color.Black, dark, 127},
+		},
+		Nearest: true,
+	}
+}
+
+// Options for DownsampleTemplate.
+type DownsampleOptions struct {
+	// Used to classify each pixel in the image. If nil, the image must use
+	// the standard colors described in the comment on
+	// NewGridMazeFromTemplate.
+	Palette *TemplatePalette
+	// The fraction of a cell's pixels that must be excluded or solid walls
+	// for the cell to be excluded (or a solid wall, whichever covers more of
+	// the cell). Must be at most 1.0. Defaults to 0.5 if not positive.
+	ExcludedCoverage float64
+}
+
+// Converts an arbitrarily large template image to a template mask with the
+// given number of cells, by dividing the image into a grid of blocks of
+// pixels, one block per cell. Each block's pixels are classified, and the
+// cell's type is chosen as follows:
+//   - If enough of the block is excluded or solid walls, as determined by
+//     opts.ExcludedCoverage, the cell is excluded or a solid wall.
+//   - Otherwise, if any pixels in the block are start, end, or waypoint
+//     markers, the cell takes the type of the most common kind of marker.
+//   - Otherwise, the cell is a room if the block contains more room pixels
+//     than normal pixels, and normal if not.
+//
+// If opts is nil, default options are used.
+func DownsampleTemplate(pic image.Image, cellsWide, cellsHigh int,
+	opts *DownsampleOptions) (*TemplateMask, error) {
+	toReturn, e := NewTemplateMask(cellsWide, cellsHigh)
+	if e != nil {
+		return nil, e
+	}
+	if opts == nil {
+		opts = &DownsampleOptions{}
+	}
+	threshold := opts.ExcludedCoverage
+	if threshold <= 0 {
+		threshold = 0.5
+	}
+	if threshold > 1.0 {
+		return nil, fmt.Errorf("Invalid excluded coverage: %f", threshold)
+	}
+	classify := colorToTemplateCellType
+	if opts.Palette != nil {
+		classify = opts.Palette.Classify
+	}
+	bounds := pic.Bounds().Canon()
+	if bounds.Empty() {
+		return nil, fmt.Errorf("The template image is empty")
+	}
+	var counts [TemplateStairs + 1]int
+	for row := 0; row < cellsHigh; row++ {
+		minY, maxY := blockRange(bounds.Min.Y, bounds.Dy(), row, cellsHigh)
+		for col := 0; col < cellsWide; col++ {
+			minX, maxX := blockRange(bounds.Min.X, bounds.Dx(), col, cellsWide)
+			for i := range counts {
+				counts[i] = 0
+			}
+			for y := minY; y < maxY; y++ {
+				for x := minX; x < maxX; x++ {
+					cellType, e := classify(pic.At(x, y))
+					if e != nil {
+						return nil, fmt.Errorf("Bad template pixel at "+
+							"(%d, %d): %w", x, y, e)
+					}
+					if int(cellType) < len(counts) {
+						counts[cellType]++
+					}
+				}
+			}
+			total := float64((maxX - minX) * (maxY - minY))
+			toReturn.Set(col, row, blockCellType(counts[:], total, threshold))
+		}
+	}
+	return toReturn, nil
+}
+
+// Returns the range of pixels, [start, end), covered by the block at the given
+// index, when splitting size pixels starting at base into the given number of
+// blocks. Always returns at least one pixel.
+func blockRange(base, size, index, blocks int) (int, int) {
+	start := base + (index*size)/blocks
+	end := base + ((index+1)*size)/blocks
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// Chooses a cell's type based on the number of pixels of each type in the
+// cell's block. Used by DownsampleTemplate.
+func blockCellType(counts []int, total, threshold float64) TemplateCellType {
+	blocked := counts[TemplateExcluded] + counts[TemplateWall]
+	if (float64(blocked) / total) >= threshold {
+		if counts[TemplateWall] > counts[TemplateExcluded] {
+			return TemplateWall
+		}
+		return TemplateExcluded
+	}
+	toReturn := TemplateNormal
+	best := 0
+	for _, t := range []TemplateCellType{TemplateStart, TemplateEnd,
+		TemplateWaypoint} {
+		if counts[t] > best {
+			toReturn = t
+			best = counts[t]
+		}
+	}
+	if best != 0 {
+		return toReturn
+	}
+	toReturn = TemplateNormal
+	best = counts[TemplateNormal]
+	for _, t := range []TemplateCellType{TemplateRoom, TemplateMud,
+		TemplateWater, TemplateStairs} {
+		if counts[t] > best {
+			toReturn = t
+			best = counts[t]
+		}
+	}
+	return toReturn
+}
+
+// Maps the characters used in plain-text templates to cell types.
+var textTemplateChars = map[rune]TemplateCellType{
+	'.': TemplateNormal,
+	'#': TemplateExcluded,
+	'S': TemplateStart,
+	'E': TemplateEnd,
+	'W': TemplateWaypoint,
+	'R': TemplateRoom,
+	'X': TemplateWall,
+	'M': TemplateMud,
+	'~': TemplateWater,
+	'H': TemplateStairs,
+}
+
+// Parses a plain-text template, containing one line of characters per row of
+// cells, and one character per cell. Every line must be the same length.
+// Trailing empty lines are ignored. The characters are:
+//   - '.' for normal cells
+//   - '#' for excluded cells
+//   - 'S' for possible start cells
+//   - 'E' for possible end cells
+//   - 'W' for waypoints
+//   - 'R' for room cells
+//   - 'X' for solid walls
+//   - 'M' for mud
+//   - '~' for water
+//   - 'H' for stairs
+//
+// See the comment on NewGridMazeFromTemplate for what each type of cell means.
+func ParseTextTemplate(r io.Reader) (*TemplateMask, error) {
+	var lines [][]TemplateCellType
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		row := make([]TemplateCellType, 0, len(line))
+		for col, c := range []rune(line) {
+			cellType, ok := textTemplateChars[c]
+			if !ok {
+				return nil, fmt.Errorf("Invalid character %q in template on "+
+					"line %d, column %d", c, lineNumber, col+1)
+			}
+			row = append(row, cellType)
+		}
+		lines = append(lines, row)
+	}
+	e := scanner.Err()
+	if e != nil {
+		return nil, fmt.Errorf("Error reading template: %w", e)
+	}
+	for (len(lines) != 0) && (len(lines[len(lines)-1]) == 0) {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("The template is empty")
+	}
+	width := len(lines[0])
+	toReturn, e := NewTemplateMask(width, len(lines))
+	if e != nil {
+		return nil, e
+	}
+	for row, line := range lines {
+		if len(line) != width {
+			return nil, fmt.Errorf("Template line %d contains %d cells, but "+
+				"line 1 contains %d", row+1, len(line), width)
+		}
+		copy(toReturn.cells[row*width:], line)
+	}
+	return toReturn, nil
+}
+
+// Writes the mask in the plain-text format read by ParseTextTemplate.
+func (t *TemplateMask) WriteText(w io.Writer) error {
+	chars := make(map[TemplateCellType]rune, len(textTemplateChars))
+	for c, cellType := range textTemplateChars {
+		chars[cellType] = c
+	}
+	line := make([]rune, t.width)
+	for row := 0; row < t.height; row++ {
+		for col := range line {
+			cellType := t.At(col, row)
+			c, ok := chars[cellType]
+			if !ok {
+				return fmt.Errorf("Can't write template cell type %s",
+					cellType)
+			}
+			line[col] = c
+		}
+		_, e := fmt.Fprintln(w, string(line))
+		if e != nil {
+			return fmt.Errorf("Error writing template: %w", e)
+		}
+	}
+	return nil
+}
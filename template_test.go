@@ -0,0 +1,173 @@
+package maze
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// Returns an image with one pixel per color, in a single row.
+func colorRow(colors ...color.Color) *image.RGBA {
+	toReturn := image.NewRGBA(image.Rect(0, 0, len(colors), 1))
+	for i, c := range colors {
+		toReturn.Set(i, 0, c)
+	}
+	return toReturn
+}
+
+func TestTemplatePaletteValidate(t *testing.T) {
+	pic := colorRow(
+		color.White,
+		color.Black,
+		color.RGBA{250, 10, 5, 255},
+		color.RGBA{0, 200, 30, 255},
+		color.RGBA{255, 128, 0, 255},
+	)
+	tests := []struct {
+		name            string
+		nearest         bool
+		exact           int
+		withinTolerance int
+		nearestMatches  int
+		unmatched       []image.Point
+	}{
+		{"strict", false, 2, 1, 0, []image.Point{{3, 0}, {4, 0}}},
+		{"nearest", true, 2, 1, 2, nil},
+	}
+	for _, test := range tests {
+		palette := DefaultTemplatePalette()
+		palette.Nearest = test.nearest
+		r := palette.Validate(pic)
+		if (r.Exact != test.exact) ||
+			(r.WithinTolerance != test.withinTolerance) ||
+			(r.NearestMatches != test.nearestMatches) {
+			t.Fatalf("%s palette: got %d exact, %d within tolerance, %d "+
+				"nearest, expected %d, %d, %d", test.name, r.Exact,
+				r.WithinTolerance, r.NearestMatches, test.exact,
+				test.withinTolerance, test.nearestMatches)
+		}
+		if len(r.Unmatched) != len(test.unmatched) {
+			t.Fatalf("%s palette: got %d unmatched pixels, expected %d",
+				test.name, len(r.Unmatched), len(test.unmatched))
+		}
+		for i, p := range test.unmatched {
+			if r.Unmatched[i] != p {
+				t.Fatalf("%s palette: unmatched pixel %d is %s, expected %s",
+					test.name, i, r.Unmatched[i], p)
+			}
+		}
+		total := 0
+		for _, count := range r.Counts {
+			total += count
+		}
+		if total != (5 - len(test.unmatched)) {
+			t.Fatalf("%s palette: counted %d cells, expected %d", test.name,
+				total, 5-len(test.unmatched))
+		}
+		if r.Counts[TemplateEnd] != 1 {
+			t.Fatalf("%s palette: the noisy red pixel wasn't an end cell",
+				test.name)
+		}
+	}
+}
+
+func TestTemplatePaletteClassify(t *testing.T) {
+	palette := DefaultTemplatePalette()
+	tests := []struct {
+		c        color.Color
+		expected TemplateCellType
+		ok       bool
+	}{
+		{color.White, TemplateNormal, true},
+		{color.RGBA{20, 20, 20, 255}, TemplateExcluded, true},
+		{color.RGBA{120, 135, 128, 255}, TemplateRoom, true},
+		{color.RGBA{240, 250, 30, 255}, TemplateWall, true},
+		{color.RGBA{128, 0, 128, 255}, TemplateNormal, false},
+	}
+	for _, test := range tests {
+		cellType, e := palette.Classify(test.c)
+		if (e == nil) != test.ok {
+			t.Fatalf("Classifying %v: got error %v, expected success = %t",
+				test.c, e, test.ok)
+		}
+		if test.ok && (cellType != test.expected) {
+			t.Fatalf("Classified %v as %s, expected %s", test.c, cellType,
+				test.expected)
+		}
+	}
+	// Without a palette, only the exact template colors are accepted.
+	_, e := TemplateMaskFromImage(colorRow(color.RGBA{250, 10, 5, 255}), nil)
+	if e == nil {
+		t.Fatalf("Didn't get an error for an inexact color without a palette")
+	}
+	mask, e := TemplateMaskFromImage(colorRow(color.RGBA{250, 10, 5, 255},
+		color.RGBA{5, 5, 250, 255}), palette)
+	if e != nil {
+		t.Fatalf("Failed converting image using a palette: %s", e)
+	}
+	if (mask.At(0, 0) != TemplateEnd) ||
+		(mask.At(1, 0) != TemplateWaypoint) {
+		t.Fatalf("Got cells %s and %s, expected end and waypoint",
+			mask.At(0, 0), mask.At(1, 0))
+	}
+}
+
+func TestParseTextTemplate(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		ok    bool
+		width int
+		rows  int
+	}{
+		{"simple", "S..\n.X.\n..E\n", true, 3, 3},
+		{"CRLF line endings", "S.R\r\n#WE\r\n", true, 3, 2},
+		{"trailing empty lines", "S.\n.E\n\n\n", true, 2, 2},
+		{"no final newline", "S.\n.E", true, 2, 2},
+		{"invalid character", "S.\n.Q\n", false, 0, 0},
+		{"ragged lines", "S..\n.E\n", false, 0, 0},
+		{"empty", "\n\n", false, 0, 0},
+	}
+	for _, test := range tests {
+		mask, e := ParseTextTemplate(strings.NewReader(test.text))
+		if (e == nil) != test.ok {
+			t.Fatalf("%s template: got error %v, expected success = %t",
+				test.name, e, test.ok)
+		}
+		if !test.ok {
+			continue
+		}
+		if (mask.Width() != test.width) || (mask.Height() != test.rows) {
+			t.Fatalf("%s template: got %dx%d cells, expected %dx%d",
+				test.name, mask.Width(), mask.Height(), test.width,
+				test.rows)
+		}
+	}
+	mask, _ := ParseTextTemplate(strings.NewReader("S.R\n#WE\n"))
+	expected := []TemplateCellType{TemplateStart, TemplateNormal,
+		TemplateRoom, TemplateExcluded, TemplateWaypoint, TemplateEnd}
+	for i, cellType := range expected {
+		if mask.At(i%3, i/3) != cellType {
+			t.Fatalf("Cell (%d, %d) is %s, expected %s", i%3, i/3,
+				mask.At(i%3, i/3), cellType)
+		}
+	}
+}
+
+func TestWriteTextRoundTrip(t *testing.T) {
+	text := "S..#\n.RR#\nXW.E\n"
+	mask, e := ParseTextTemplate(strings.NewReader(text))
+	if e != nil {
+		t.Fatalf("Failed parsing template: %s", e)
+	}
+	var b bytes.Buffer
+	e = mask.WriteText(&b)
+	if e != nil {
+		t.Fatalf("Failed writing template: %s", e)
+	}
+	if b.String() != text {
+		t.Fatalf("Wrote template %q, expected %q", b.String(), text)
+	}
+}
@@ -0,0 +1,305 @@
+package maze
+
+// This file contains support for terrain and passage costs in a GridMaze, and
+// a solver that finds the cheapest, rather than the shortest, solution.
+
+import (
+	"container/heap"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Identifies the type of ground in a cell, which determines the cost of
+// moving into the cell.
+type Terrain uint8
+
+const (
+	// Ordinary ground, with a default cost of 1.
+	TerrainNormal Terrain = iota
+	// Mud, with a default cost of 3.
+	TerrainMud
+	// Water, with a default cost of 5.
+	TerrainWater
+	// Stairs, with a default cost of 2.
+	TerrainStairs
+)
+
+func (t Terrain) String() string {
+	switch t {
+	case TerrainNormal:
+		return "normal"
+	case TerrainMud:
+		return "mud"
+	case TerrainWater:
+		return "water"
+	case TerrainStairs:
+		return "stairs"
+	}
+	return fmt.Sprintf("Unknown Terrain: %d", uint8(t))
+}
+
+// Returns the cost of entering a cell with the terrain, unless it has been
+// changed using SetTerrainCost.
+func (t Terrain) DefaultCost() int {
+	switch t {
+	case TerrainMud:
+		return 3
+	case TerrainWater:
+		return 5
+	case TerrainStairs:
+		return 2
+	}
+	return 1
+}
+
+// Returns the color used to fill open space in cells with the terrain.
+func (t Terrain) color() color.Color {
+	switch t {
+	case TerrainMud:
+		return color.RGBA{200, 160, 110, 255}
+	case TerrainWater:
+		return color.RGBA{150, 200, 255, 255}
+	case TerrainStairs:
+		return color.RGBA{215, 185, 230, 255}
+	}
+	return color.White
+}
+
+// Sets the terrain in the given cell.
+func (m *GridMaze) SetTerrain(cell image.Point, t Terrain) error {
+	index, e := m.cellIndex(cell)
+	if e != nil {
+		return e
+	}
+	if t > TerrainStairs {
+		return fmt.Errorf("Invalid terrain: %s", t)
+	}
+	m.cells[index].terrain = t
+	return nil
+}
+
+// Returns the terrain in the given cell, or TerrainNormal if the cell is
+// outside of the maze.
+func (m *GridMaze) Terrain(cell image.Point) Terrain {
+	index, e := m.cellIndex(cell)
+	if e != nil {
+		return TerrainNormal
+	}
+	return m.cells[index].terrain
+}
+
+// Changes the cost of entering cells with the given terrain in this maze. The
+// cost must be at least 1.
+func (m *GridMaze) SetTerrainCost(t Terrain, cost int) error {
+	if cost < 1 {
+		return fmt.Errorf("Invalid cost for %s terrain: %d", t, cost)
+	}
+	if m.terrainCosts == nil {
+		m.terrainCosts = make(map[Terrain]int)
+	}
+	m.terrainCosts[t] = cost
+	return nil
+}
+
+// Returns the cost of entering a cell with the given terrain in this maze.
+func (m *GridMaze) TerrainCost(t Terrain) int {
+	cost, ok := m.terrainCosts[t]
+	if ok {
+		return cost
+	}
+	return t.DefaultCost()
+}
+
+// Sets the cost of moving through the passage in the given direction of the
+// given cell, in either direction. This overrides the cost of the terrain in
+// the cells on either side. A cost of 0 or less removes the override.
+func (m *GridMaze) SetPassageCost(cell image.Point, direction int,
+	cost int) error {
+	index, e := m.cellIndex(cell)
+	if e != nil {
+		return e
+	}
+	if (direction < 0) || (direction > 3) {
+		return fmt.Errorf("Invalid direction: %d", direction)
+	}
+	id := m.passageID(index, direction)
+	if id < 0 {
+		return fmt.Errorf("Direction %d from cell %s leads outside the maze",
+			direction, cell)
+	}
+	if cost <= 0 {
+		delete(m.passageCosts, id)
+		return nil
+	}
+	if m.passageCosts == nil {
+		m.passageCosts = make(map[int]int)
+	}
+	m.passageCosts[id] = cost
+	return nil
+}
+
+// Returns the cost of moving from the cell at index from to the adjacent or
+// portal-linked cell at index to. This is the passage's cost if one was set
+// using SetPassageCost, and the cost of the destination cell's terrain
+// otherwise.
+func (m *GridMaze) moveCost(from, to int) int {
+	if len(m.passageCosts) != 0 {
+		dir := m.directionBetween(from, to)
+		if dir >= 0 {
+			cost, ok := m.passageCosts[m.passageID(from, dir)]
+			if ok {
+				return cost
+			}
+		}
+	}
+	return m.TerrainCost(m.cells[to].terrain)
+}
+
+// A single entry in a priorityQueue. The priority is copied into the entry
+// when it's pushed, so later changes to a cell's cost can't break the heap's
+// ordering. Cells whose cost decreases are pushed again, and the stale copy is
+// skipped when it's popped.
+type queueEntry struct {
+	cell     int
+	priority float64
+}
+
+// A min-heap of cells, ordered by priority. Used by the cost-aware solvers.
+type priorityQueue []queueEntry
+
+func (q priorityQueue) Len() int {
+	return len(q)
+}
+
+func (q priorityQueue) Less(a, b int) bool {
+	return q[a].priority < q[b].priority
+}
+
+func (q priorityQueue) Swap(a, b int) {
+	q[a], q[b] = q[b], q[a]
+}
+
+func (q *priorityQueue) Push(v interface{}) {
+	*q = append(*q, v.(queueEntry))
+}
+
+func (q *priorityQueue) Pop() interface{} {
+	last := (*q)[len(*q)-1]
+	*q = (*q)[:len(*q)-1]
+	return last
+}
+
+// Uses Dijkstra's algorithm to find the cheapest cost of reaching each cell
+// from the cell at startIndex. Returns the costs and the index of the cell
+// from which each cell was reached, in the same format as
+// breadthFirstSearch.
+func (m *GridMaze) cheapestCosts(startIndex int) ([]int, []int) {
+	costs := make([]int, len(m.cells))
+	parents := make([]int, len(m.cells))
+	done := make([]bool, len(m.cells))
+	for i := range costs {
+		costs[i] = -1
+		parents[i] = -1
+	}
+	costs[startIndex] = 0
+	q := &priorityQueue{{startIndex, 0}}
+	var neighbors []int
+	for q.Len() != 0 {
+		current := heap.Pop(q).(queueEntry).cell
+		// Cells are pushed again whenever their cost decreases, so skip the
+		// stale copies.
+		if done[current] {
+			continue
+		}
+		done[current] = true
+		neighbors = m.openNeighbors(current, neighbors[:0])
+		for _, n := range neighbors {
+			if done[n] {
+				continue
+			}
+			cost := costs[current] + m.moveCost(current, n)
+			if (costs[n] >= 0) && (costs[n] <= cost) {
+				continue
+			}
+			costs[n] = cost
+			parents[n] = current
+			heap.Push(q, queueEntry{n, float64(cost)})
+		}
+	}
+	return costs, parents
+}
+
+// Returns the cheapest cost of reaching every cell in the maze from the given
+// cell, in the same layout as DistancesFrom. Unreachable cells have a cost of
+// -1.
+func (m *GridMaze) CostsFrom(cell image.Point) ([]int, error) {
+	index, e := m.cellIndex(cell)
+	if e != nil {
+		return nil, e
+	}
+	costs, _ := m.cheapestCosts(index)
+	return costs, nil
+}
+
+// A path through a maze, along with the total cost of following it.
+type WeightedPath struct {
+	// The cells along the path, in order. May visit some cells more than
+	// once if the maze has waypoints.
+	Cells []image.Point
+	// The sum of the cost of every move along the path.
+	Cost int
+}
+
+// Returns the cheapest route from the start to the end, passing through any
+// waypoints in order, as an ordered list of cell indices, along with its
+// total cost.
+func (m *GridMaze) cheapestRoute() ([]int, int, error) {
+	stops := m.routeStops()
+	route := []int{m.startCellIndex}
+	total := 0
+	for i := 1; i < len(stops); i++ {
+		costs, parents := m.cheapestCosts(stops[i-1])
+		leg := pathFromParents(costs, parents, stops[i])
+		if leg == nil {
+			start := m.cellPoint(stops[i-1])
+			end := m.cellPoint(stops[i])
+			return nil, 0, fmt.Errorf("Cell (%d, %d) can't be reached from "+
+				"cell (%d, %d)", end.X, end.Y, start.X, start.Y)
+		}
+		route = append(route, leg[1:]...)
+		total += costs[stops[i]]
+	}
+	return route, total, nil
+}
+
+// Finds the solution with the lowest total cost, taking terrain and passage
+// costs into account, rather than the one with the fewest moves.
+func (m *GridMaze) CheapestSolution() (*WeightedPath, error) {
+	route, cost, e := m.cheapestRoute()
+	if e != nil {
+		return nil, e
+	}
+	toReturn := &WeightedPath{
+		Cells: make([]image.Point, len(route)),
+		Cost:  cost,
+	}
+	for i, index := range route {
+		toReturn.Cells[i] = m.cellPoint(index)
+	}
+	return toReturn, nil
+}
+
+// Like ShowSolution(true), but highlights the solution found by
+// CheapestSolution.
+func (m *GridMaze) ShowCheapestSolution() error {
+	route, _, e := m.cheapestRoute()
+	if e != nil {
+		return e
+	}
+	m.clearSolution()
+	for _, index := range route {
+		m.cells[index].state = 1
+	}
+	return nil
+}
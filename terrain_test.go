@@ -0,0 +1,192 @@
+package maze
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// Gives each cell in the maze a random terrain, and gives mud and water
+// random costs.
+func randomizeTerrain(m *GridMaze, rng *rand.Rand, maxMud, maxWater int) {
+	for i := range m.cells {
+		m.cells[i].terrain = Terrain(rng.Intn(int(TerrainStairs) + 1))
+	}
+	m.SetTerrainCost(TerrainMud, 1+rng.Intn(maxMud))
+	m.SetTerrainCost(TerrainWater, 1+rng.Intn(maxWater))
+}
+
+// Returns the cheapest cost of reaching each cell from startIndex, computed
+// using the Bellman-Ford algorithm. Unreachable cells have a cost of -1.
+func bellmanFordCosts(m *GridMaze, startIndex int) []int {
+	costs := make([]int, len(m.cells))
+	for i := range costs {
+		costs[i] = -1
+	}
+	costs[startIndex] = 0
+	var neighbors []int
+	for changed := true; changed; {
+		changed = false
+		for i := range m.cells {
+			if costs[i] < 0 {
+				continue
+			}
+			neighbors = m.openNeighbors(i, neighbors[:0])
+			for _, n := range neighbors {
+				cost := costs[i] + m.moveCost(i, n)
+				if (costs[n] < 0) || (cost < costs[n]) {
+					costs[n] = cost
+					changed = true
+				}
+			}
+		}
+	}
+	return costs
+}
+
+// Returns the cheapest cost of following the maze's route, computed using
+// bellmanFordCosts, or -1 if the route can't be followed.
+func bruteForceRouteCost(m *GridMaze) int {
+	stops := m.routeStops()
+	total := 0
+	for i := 1; i < len(stops); i++ {
+		cost := bellmanFordCosts(m, stops[i-1])[stops[i]]
+		if cost < 0 {
+			return -1
+		}
+		total += cost
+	}
+	return total
+}
+
+// Fails the test unless the path's cost is the sum of the cost of each move
+// along it.
+func checkPathCost(t *testing.T, m *GridMaze, path []image.Point,
+	cost int) {
+	total := 0
+	for i := 1; i < len(path); i++ {
+		a, _ := m.cellIndex(path[i-1])
+		b, _ := m.cellIndex(path[i])
+		total += m.moveCost(a, b)
+	}
+	if total != cost {
+		t.Fatalf("Path's moves cost %d, but its reported cost is %d", total,
+			cost)
+	}
+}
+
+func TestCheapestSolution(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		erosion int
+	}{
+		{"perfect", 12, 0},
+		{"braided", 12, 5},
+		{"heavily braided", 20, 20},
+	}
+	for _, test := range tests {
+		for seed := int64(1); seed <= 20; seed++ {
+			m := newTestMaze(t, test.size, test.size, seed, test.erosion)
+			rng := rand.New(rand.NewSource(seed))
+			randomizeTerrain(m, rng, 20, 50)
+			path, e := m.CheapestSolution()
+			if e != nil {
+				t.Fatalf("%s maze, seed %d: failed solving: %s", test.name,
+					seed, e)
+			}
+			expected := bruteForceRouteCost(m)
+			if path.Cost != expected {
+				t.Fatalf("%s maze, seed %d: got cost %d, expected %d",
+					test.name, seed, path.Cost, expected)
+			}
+			checkWalk(t, m, path.Cells, m.StartCell(), m.EndCell())
+			checkPathCost(t, m, path.Cells, path.Cost)
+		}
+	}
+}
+
+func TestCheapestSolutionLoop(t *testing.T) {
+	// A ring of six cells, with two equally short routes from the start at
+	// the top left to the end at the bottom right.
+	m := buildMaze(t, 3, 2, [][2]image.Point{
+		{{0, 0}, {1, 0}},
+		{{1, 0}, {2, 0}},
+		{{2, 0}, {2, 1}},
+		{{0, 0}, {0, 1}},
+		{{0, 1}, {1, 1}},
+		{{1, 1}, {2, 1}},
+	})
+	top := []image.Point{{0, 0}, {1, 0}, {2, 0}, {2, 1}}
+	bottom := []image.Point{{0, 0}, {0, 1}, {1, 1}, {2, 1}}
+	m.SetTerrain(image.Pt(1, 0), TerrainWater)
+	tests := []struct {
+		name string
+		// The cost of the passage between (0, 0) and (0, 1), or 0 to use
+		// the terrain's cost.
+		passageCost int
+		expected    []image.Point
+		cost        int
+	}{
+		{"water on top", 0, bottom, 3},
+		{"expensive passage on bottom", 10, top, 7},
+	}
+	for _, test := range tests {
+		e := m.SetPassageCost(image.Pt(0, 0), 3, test.passageCost)
+		if e != nil {
+			t.Fatalf("Failed setting passage cost: %s", e)
+		}
+		path, e := m.CheapestSolution()
+		if e != nil {
+			t.Fatalf("%s: failed solving: %s", test.name, e)
+		}
+		if path.Cost != test.cost {
+			t.Fatalf("%s: got cost %d, expected %d", test.name, path.Cost,
+				test.cost)
+		}
+		for i, p := range test.expected {
+			if path.Cells[i] != p {
+				t.Fatalf("%s: step %d is %s, expected %s", test.name, i,
+					path.Cells[i], p)
+			}
+		}
+	}
+}
+
+func TestSetTerrainCost(t *testing.T) {
+	m := newTestMaze(t, 4, 4, 1, 0)
+	if m.TerrainCost(TerrainWater) != TerrainWater.DefaultCost() {
+		t.Fatalf("Water doesn't have its default cost")
+	}
+	e := m.SetTerrainCost(TerrainWater, 0)
+	if e == nil {
+		t.Fatalf("Didn't get an error setting a cost of 0")
+	}
+	e = m.SetTerrainCost(TerrainWater, 7)
+	if e != nil {
+		t.Fatalf("Failed setting water cost: %s", e)
+	}
+	if m.TerrainCost(TerrainWater) != 7 {
+		t.Fatalf("Got water cost %d, expected 7", m.TerrainCost(TerrainWater))
+	}
+}
+
+func TestHeatMapCoversTerrain(t *testing.T) {
+	m := newTestMaze(t, 6, 6, 1, 0)
+	cell := image.Pt(3, 2)
+	e := m.SetTerrain(cell, TerrainWater)
+	if e != nil {
+		t.Fatalf("Failed setting terrain: %s", e)
+	}
+	pic, e := m.DistanceHeatMap(m.StartCell(), nil)
+	if e != nil {
+		t.Fatalf("Failed getting heat map: %s", e)
+	}
+	x := cell.X*m.cellPixels + m.cellPixels/2
+	y := cell.Y*m.cellPixels + m.cellPixels/2
+	r, g, b, _ := pic.At(x, y).RGBA()
+	waterR, waterG, waterB, _ := TerrainWater.color().RGBA()
+	if (r == waterR) && (g == waterG) && (b == waterB) {
+		t.Fatalf("The heat map didn't color the water cell's floor")
+	}
+}
@@ -0,0 +1,227 @@
+package maze
+
+// This file contains functions for building template masks shaped like text,
+// using a small built-in bitmap font.
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// The width and height of each glyph in the built-in font, in font pixels.
+const (
+	fontGlyphWidth  = 5
+	fontGlyphHeight = 7
+)
+
+// The built-in 5x7 bitmap font. Each entry contains one byte per row of the
+// glyph, from top to bottom. The lowest five bits of each byte are the row's
+// pixels, with the most significant of them on the left.
+var bitmapFont = map[rune][fontGlyphHeight]uint8{
+	'A':  {0x0e, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'B':  {0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e},
+	'C':  {0x0e, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0e},
+	'D':  {0x1e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1e},
+	'E':  {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x1f},
+	'F':  {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x10},
+	'G':  {0x0e, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0f},
+	'H':  {0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'I':  {0x0e, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'J':  {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0c},
+	'K':  {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L':  {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1f},
+	'M':  {0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N':  {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O':  {0x0e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'P':  {0x1e, 0x11, 0x11, 0x1e, 0x10, 0x10, 0x10},
+	'Q':  {0x0e, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0d},
+	'R':  {0x1e, 0x11, 0x11, 0x1e, 0x14, 0x12, 0x11},
+	'S':  {0x0f, 0x10, 0x10, 0x0e, 0x01, 0x01, 0x1e},
+	'T':  {0x1f, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'V':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x0a, 0x04},
+	'W':  {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0a},
+	'X':  {0x11, 0x11, 0x0a, 0x04, 0x0a, 0x11, 0x11},
+	'Y':  {0x11, 0x11, 0x0a, 0x04, 0x04, 0x04, 0x04},
+	'Z':  {0x1f, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1f},
+	'0':  {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1':  {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2':  {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3':  {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4':  {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5':  {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6':  {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7':  {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8':  {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9':  {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	' ':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	'!':  {0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04},
+	'?':  {0x0e, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+	'.':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c},
+	',':  {0x00, 0x00, 0x00, 0x00, 0x0c, 0x04, 0x08},
+	'-':  {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},
+	'\'': {0x0c, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00},
+	':':  {0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x0c, 0x00},
+	'&':  {0x0c, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0d},
+	'+':  {0x00, 0x04, 0x04, 0x1f, 0x04, 0x04, 0x00},
+	'#':  {0x0a, 0x0a, 0x1f, 0x0a, 0x1f, 0x0a, 0x0a},
+	'<':  {0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02},
+	'>':  {0x08, 0x04, 0x02, 0x01, 0x02, 0x04, 0x08},
+	'*':  {0x00, 0x04, 0x15, 0x0e, 0x15, 0x04, 0x00},
+}
+
+// Options for TextTemplate.
+type TextTemplateOptions struct {
+	// The width and height of each font pixel, in maze cells. Must be at
+	// least 1. Larger values produce wider paths within each letter. Defaults
+	// to 3 if not positive.
+	Scale int
+	// The number of empty font pixels between adjacent letters, and between
+	// lines of text. Defaults to 1 if negative.
+	Spacing int
+}
+
+// Returns true if the text contains only characters supported by the built-in
+// font. Lowercase letters are supported, but are drawn as uppercase.
+func TextSupported(text string) bool {
+	_, ok := firstUnsupportedRune(text)
+	return !ok
+}
+
+// Returns the first character in the text that isn't supported by the
+// built-in font, and true. Returns false if every character is supported.
+func firstUnsupportedRune(text string) (rune, bool) {
+	for _, c := range text {
+		if c == '\n' {
+			continue
+		}
+		_, ok := bitmapFont[unicode.ToUpper(c)]
+		if !ok {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// Returns a template mask shaped like the given text, drawn using a built-in
+// 5x7 bitmap font supporting letters, digits, and some punctuation. The text
+// may contain multiple lines, separated by newlines. The letters' strokes
+// become normal cells, and everything else is excluded, apart from the
+// shortest possible bridges needed to connect separate letters. The start is
+// placed at the leftmost cell of the text, and the end at the rightmost. If
+// opts is nil, default options are used.
+//
+// To use other fonts, draw the text into an image (e.g. using the
+// golang.org/x/image/font package) and convert it to a mask using
+// DownsampleTemplate with SilhouettePalette(true).
+func TextTemplate(text string, opts *TextTemplateOptions) (*TemplateMask,
+	error) {
+	if opts == nil {
+		opts = &TextTemplateOptions{}
+	}
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = 3
+	}
+	spacing := opts.Spacing
+	if spacing < 0 {
+		spacing = 1
+	}
+	lines := strings.Split(text, "\n")
+	maxLength := 0
+	for _, line := range lines {
+		length := len([]rune(line))
+		if length > maxLength {
+			maxLength = length
+		}
+	}
+	if maxLength == 0 {
+		return nil, fmt.Errorf("The text is empty")
+	}
+	c, unsupported := firstUnsupportedRune(text)
+	if unsupported {
+		return nil, fmt.Errorf("The built-in font doesn't support %q", c)
+	}
+	// The size of the text in font pixels.
+	fontWidth := maxLength*(fontGlyphWidth+spacing) - spacing
+	fontHeight := len(lines)*(fontGlyphHeight+spacing) - spacing
+	toReturn, e := NewTemplateMask(fontWidth*scale, fontHeight*scale)
+	if e != nil {
+		return nil, e
+	}
+	for i := range toReturn.cells {
+		toReturn.cells[i] = TemplateExcluded
+	}
+	for lineIndex, line := range lines {
+		top := lineIndex * (fontGlyphHeight + spacing)
+		for charIndex, c := range []rune(line) {
+			left := charIndex * (fontGlyphWidth + spacing)
+			toReturn.drawGlyph(bitmapFont[unicode.ToUpper(c)], left, top,
+				scale)
+		}
+	}
+	e = toReturn.markTextEndpoints()
+	if e != nil {
+		return nil, e
+	}
+	e = toReturn.ResolveIslands(IslandsBridge)
+	if e != nil {
+		return nil, fmt.Errorf("Error connecting letters: %w", e)
+	}
+	return toReturn, nil
+}
+
+// Sets the cells covered by the glyph's pixels to TemplateNormal. The glyph's
+// top-left corner is at the given position, in font pixels.
+func (t *TemplateMask) drawGlyph(glyph [fontGlyphHeight]uint8, left, top,
+	scale int) {
+	for row, bits := range glyph {
+		for col := 0; col < fontGlyphWidth; col++ {
+			if (bits & (1 << (fontGlyphWidth - 1 - col))) == 0 {
+				continue
+			}
+			baseCol := (left + col) * scale
+			baseRow := (top + row) * scale
+			for y := 0; y < scale; y++ {
+				for x := 0; x < scale; x++ {
+					t.Set(baseCol+x, baseRow+y, TemplateNormal)
+				}
+			}
+		}
+	}
+}
+
+// Marks the top cell in the leftmost non-excluded column as the start, and
+// the bottom cell in the rightmost non-excluded column as the end.
+func (t *TemplateMask) markTextEndpoints() error {
+	start := -1
+StartLoop:
+	for col := 0; col < t.width; col++ {
+		for row := 0; row < t.height; row++ {
+			if t.At(col, row) != TemplateExcluded {
+				start = row*t.width + col
+				break StartLoop
+			}
+		}
+	}
+	if start < 0 {
+		return fmt.Errorf("The text doesn't contain any visible characters")
+	}
+	end := -1
+EndLoop:
+	for col := t.width - 1; col >= 0; col-- {
+		for row := t.height - 1; row >= 0; row-- {
+			if t.At(col, row) != TemplateExcluded {
+				end = row*t.width + col
+				break EndLoop
+			}
+		}
+	}
+	if start == end {
+		return fmt.Errorf("The text is too small to contain a maze")
+	}
+	t.cells[start] = TemplateStart
+	t.cells[end] = TemplateEnd
+	return nil
+}
@@ -0,0 +1,137 @@
+package maze
+
+// This file contains functions for rendering only part of a GridMaze at a
+// time, which is necessary for mazes that are too big to rasterize as a single
+// image.
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// Rasterizes the part of the maze within the given rectangle, in pixel
+// coordinates. The returned image's bounds will be the intersection of rect
+// with the maze's bounds, so pixel coordinates in the returned image match the
+// coordinates in the full maze. Returns an error if the rectangle doesn't
+// overlap the maze at all.
+func (m *GridMaze) RenderRegion(rect image.Rectangle) (*image.RGBA, error) {
+	rect = rect.Canon().Intersect(m.Bounds())
+	if rect.Empty() {
+		return nil, fmt.Errorf("The region doesn't overlap the maze")
+	}
+	toReturn := image.NewRGBA(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			toReturn.Set(x, y, m.At(x, y))
+		}
+	}
+	return toReturn, nil
+}
+
+// Returns the deepest zoom level used when splitting the maze into square
+// tiles that are tileSize pixels wide. At the returned zoom level, one tile
+// pixel corresponds to one maze pixel. At zoom level 0, the entire maze fits
+// in a single tile.
+func (m *GridMaze) MaxTileZoom(tileSize int) int {
+	bounds := m.Bounds()
+	size := bounds.Dx()
+	if bounds.Dy() > size {
+		size = bounds.Dy()
+	}
+	zoom := 0
+	for (tileSize << zoom) < size {
+		zoom++
+	}
+	return zoom
+}
+
+// Renders a single tileSize x tileSize deep-zoom tile of the maze. The zoom
+// level must be between 0 and m.MaxTileZoom(tileSize), inclusive. At zoom
+// levels less than the maximum, each tile pixel is sampled from the center of
+// the block of maze pixels it covers, so only tileSize * tileSize pixels are
+// ever computed. Portions of the tile outside of the maze are transparent.
+func (m *GridMaze) RenderTile(zoom, tileX, tileY, tileSize int) (*image.RGBA,
+	error) {
+	if tileSize < 1 {
+		return nil, fmt.Errorf("Tile size must be at least 1 pixel")
+	}
+	maxZoom := m.MaxTileZoom(tileSize)
+	if (zoom < 0) || (zoom > maxZoom) {
+		return nil, fmt.Errorf("Invalid zoom level %d (must be between 0 "+
+			"and %d)", zoom, maxZoom)
+	}
+	// The number of maze pixels covered by a single tile pixel.
+	scale := 1 << (maxZoom - zoom)
+	tilesPerSide := 1 << zoom
+	if (tileX < 0) || (tileY < 0) || (tileX >= tilesPerSide) ||
+		(tileY >= tilesPerSide) {
+		return nil, fmt.Errorf("Invalid tile coordinate (%d, %d) for zoom "+
+			"level %d", tileX, tileY, zoom)
+	}
+	toReturn := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+	baseX := tileX * tileSize * scale
+	baseY := tileY * tileSize * scale
+	for y := 0; y < tileSize; y++ {
+		mazeY := baseY + y*scale + scale/2
+		for x := 0; x < tileSize; x++ {
+			mazeX := baseX + x*scale + scale/2
+			toReturn.Set(x, y, m.At(mazeX, mazeY))
+		}
+	}
+	return toReturn, nil
+}
+
+// Writes a full pyramid of deep-zoom tiles for the maze to the given
+// directory, using the <dir>/<zoom>/<x>/<y>.png layout expected by most web
+// map viewers. Tiles lying entirely outside of the maze are not written.
+// Returns the maximum zoom level that was written.
+func (m *GridMaze) WriteTiles(dir string, tileSize int) (int, error) {
+	if tileSize < 1 {
+		return 0, fmt.Errorf("Tile size must be at least 1 pixel")
+	}
+	maxZoom := m.MaxTileZoom(tileSize)
+	bounds := m.Bounds()
+	for zoom := 0; zoom <= maxZoom; zoom++ {
+		// The number of maze pixels covered by an entire tile at this zoom.
+		tileSpan := tileSize << (maxZoom - zoom)
+		tilesWide := (bounds.Dx() + tileSpan - 1) / tileSpan
+		tilesHigh := (bounds.Dy() + tileSpan - 1) / tileSpan
+		for tileX := 0; tileX < tilesWide; tileX++ {
+			colDir := filepath.Join(dir, fmt.Sprintf("%d", zoom),
+				fmt.Sprintf("%d", tileX))
+			e := os.MkdirAll(colDir, 0755)
+			if e != nil {
+				return 0, fmt.Errorf("Error creating tile directory: %w", e)
+			}
+			for tileY := 0; tileY < tilesHigh; tileY++ {
+				tile, e := m.RenderTile(zoom, tileX, tileY, tileSize)
+				if e != nil {
+					return 0, e
+				}
+				e = writePNG(filepath.Join(colDir, fmt.Sprintf("%d.png",
+					tileY)), tile)
+				if e != nil {
+					return 0, e
+				}
+			}
+		}
+	}
+	return maxZoom, nil
+}
+
+// Saves the given image to a PNG file with the given path.
+func writePNG(path string, pic image.Image) error {
+	f, e := os.Create(path)
+	if e != nil {
+		return fmt.Errorf("Error creating %s: %w", path, e)
+	}
+	e = png.Encode(f, pic)
+	if e != nil {
+		f.Close()
+		return fmt.Errorf("Error writing %s: %w", path, e)
+	}
+	return f.Close()
+}
@@ -0,0 +1,112 @@
+package maze
+
+// This file contains functions for solving mazes that require visiting a
+// sequence of checkpoints between the start and the end.
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Sets the cells that the solution must visit, in order, between the start
+// and the end. Replaces any waypoints marked in the maze's template. Pass an
+// empty slice to remove all checkpoints.
+func (m *GridMaze) SetCheckpoints(cells []image.Point) error {
+	indices := make([]int, len(cells))
+	for i, p := range cells {
+		index, e := m.cellIndex(p)
+		if e != nil {
+			return e
+		}
+		if m.cells[index].state.excluded() {
+			return fmt.Errorf("Checkpoint (%d, %d) is excluded from the maze",
+				p.X, p.Y)
+		}
+		indices[i] = index
+	}
+	m.waypoints = indices
+	return nil
+}
+
+// Returns the cells the solution must visit between the start and end, in
+// order.
+func (m *GridMaze) Checkpoints() []image.Point {
+	toReturn := make([]image.Point, len(m.waypoints))
+	for i, index := range m.waypoints {
+		toReturn[i] = m.cellPoint(index)
+	}
+	return toReturn
+}
+
+// A route from the start to the end of a maze, via each checkpoint.
+type Tour struct {
+	// Legs[0] goes from the start to the first checkpoint, Legs[1] from the
+	// first checkpoint to the second, and so on, with the final leg ending
+	// at the end. Each leg includes both of its endpoints, so the last cell
+	// of each leg is the first cell of the next.
+	Legs [][]image.Point
+	// The total number of moves required to complete the tour.
+	Length int
+}
+
+// Computes the shortest route from the start to the end that visits each
+// checkpoint in order. Returns an error if any leg can't be completed.
+func (m *GridMaze) Tour() (*Tour, error) {
+	stops := m.routeStops()
+	toReturn := &Tour{
+		Legs: make([][]image.Point, 0, len(stops)-1),
+	}
+	for i := 1; i < len(stops); i++ {
+		path := m.shortestPath(stops[i-1], stops[i])
+		if path == nil {
+			from := m.cellPoint(stops[i-1])
+			to := m.cellPoint(stops[i])
+			return nil, fmt.Errorf("Leg %d of the tour can't be completed: "+
+				"cell (%d, %d) can't be reached from cell (%d, %d)", i, to.X,
+				to.Y, from.X, from.Y)
+		}
+		leg := make([]image.Point, len(path))
+		for j, index := range path {
+			leg[j] = m.cellPoint(index)
+		}
+		toReturn.Legs = append(toReturn.Legs, leg)
+		toReturn.Length += len(path) - 1
+	}
+	return toReturn, nil
+}
+
+// Returns the colors used for tour legs if no other colors are specified.
+func DefaultLegColors() []color.Color {
+	return []color.Color{
+		color.RGBA{230, 20, 20, 255},
+		color.RGBA{40, 110, 230, 255},
+		color.RGBA{30, 170, 60, 255},
+		color.RGBA{230, 140, 20, 255},
+		color.RGBA{150, 50, 200, 255},
+		color.RGBA{20, 180, 180, 255},
+	}
+}
+
+// Returns an image of the maze with each leg of the tour drawn in a different
+// color. Legs use the given colors in order, repeating them if there are more
+// legs than colors. Uses DefaultLegColors() if no colors are given. Where
+// legs overlap, later legs are drawn on top of earlier ones.
+func (m *GridMaze) RenderTour(t *Tour, colors []color.Color) (image.Image,
+	error) {
+	if len(colors) == 0 {
+		colors = DefaultLegColors()
+	}
+	overlay := newCellOverlay(m.copyWithoutSolution())
+	for i, leg := range t.Legs {
+		c := colors[i%len(colors)]
+		for _, p := range leg {
+			index, e := m.cellIndex(p)
+			if e != nil {
+				return nil, e
+			}
+			overlay.colors[index] = c
+		}
+	}
+	return overlay, nil
+}
@@ -0,0 +1,208 @@
+package maze
+
+// This file contains functions for checking that a GridMaze's walls are
+// consistent, which is useful after editing or deserializing a maze.
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// Identifies a problem found by GridMaze.Validate.
+type ViolationKind uint8
+
+const (
+	// A cell and its neighbor disagree about whether there's a wall between
+	// them.
+	ViolationNonReciprocalWall ViolationKind = iota
+	// A cell has an opening leading out of the maze, or into a solid wall,
+	// but isn't an entrance or exit.
+	ViolationOpenEdge
+	// A cell can't be reached from the start.
+	ViolationDisconnected
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case ViolationNonReciprocalWall:
+		return "nonReciprocalWall"
+	case ViolationOpenEdge:
+		return "openEdge"
+	case ViolationDisconnected:
+		return "disconnected"
+	}
+	return fmt.Sprintf("Unknown ViolationKind: %d", uint8(k))
+}
+
+// A single problem found by GridMaze.Validate.
+type MazeViolation struct {
+	Kind ViolationKind
+	// The column and row of the cell with the problem.
+	Cell image.Point
+	// The direction of the offending wall from the cell, or -1 if the
+	// problem doesn't involve a wall.
+	Direction int
+}
+
+func (v MazeViolation) String() string {
+	if v.Direction < 0 {
+		return fmt.Sprintf("%s at cell (%d, %d)", v.Kind, v.Cell.X, v.Cell.Y)
+	}
+	return fmt.Sprintf("%s at cell (%d, %d), direction %d", v.Kind, v.Cell.X,
+		v.Cell.Y, v.Direction)
+}
+
+// The result of GridMaze.Validate.
+type ValidationReport struct {
+	// Every problem found in the maze, in order of cell index.
+	Violations []MazeViolation
+	// The number of passages beyond those needed to connect every cell, as
+	// in MazeStats.Loops.
+	Loops int
+	// True if the maze is valid, and every pair of cells is connected by
+	// exactly one path, i.e., the passages form a tree.
+	Perfect bool
+}
+
+// Returns true if no violations were found.
+func (r *ValidationReport) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+// Returns a human-readable summary of the report, listing at most the first
+// 10 violations.
+func (r *ValidationReport) String() string {
+	var b strings.Builder
+	if r.Valid() {
+		b.WriteString("The maze is valid")
+		if r.Perfect {
+			b.WriteString(" and perfect")
+		} else {
+			fmt.Fprintf(&b, ", with %d loop(s)", r.Loops)
+		}
+		return b.String()
+	}
+	fmt.Fprintf(&b, "The maze has %d violation(s)", len(r.Violations))
+	for i, v := range r.Violations {
+		if i >= 10 {
+			b.WriteString("\n  ...")
+			break
+		}
+		fmt.Fprintf(&b, "\n  %s", v)
+	}
+	return b.String()
+}
+
+// Checks the maze for structural problems: walls that aren't the same on
+// both sides, openings out of the maze other than at the entrances and
+// exits, and cells that can't be reached from the start through passages or
+// portals. Also determines whether the maze is perfect. Walls between a cell
+// and an excluded cell outside of the maze aren't required to match, since
+// only the cell inside the maze is drawn.
+func (m *GridMaze) Validate() *ValidationReport {
+	toReturn := &ValidationReport{}
+	addViolation := func(kind ViolationKind, cellIndex, direction int) {
+		toReturn.Violations = append(toReturn.Violations, MazeViolation{
+			Kind:      kind,
+			Cell:      m.cellPoint(cellIndex),
+			Direction: direction,
+		})
+	}
+	isEndpoint := make([]bool, len(m.cells))
+	for _, index := range m.allStarts() {
+		isEndpoint[index] = true
+	}
+	for _, index := range m.allEnds() {
+		isEndpoint[index] = true
+	}
+
+	for i := range m.cells {
+		c := &(m.cells[i])
+		for dir := 0; dir < 4; dir++ {
+			neighbor := m.neighborInDirection(i, dir)
+			// Each pair of cells inside the maze only needs to be checked
+			// once.
+			if (neighbor >= 0) && (dir >= 2) && !c.state.outside() &&
+				!m.cells[neighbor].state.outside() &&
+				(c.walls[dir] != m.cells[neighbor].walls[(dir+2)%4]) {
+				addViolation(ViolationNonReciprocalWall, i, dir)
+			}
+			if c.state.excluded() || c.walls[dir] {
+				continue
+			}
+			if (neighbor >= 0) && !m.cells[neighbor].state.excluded() {
+				continue
+			}
+			// This is an opening out of the maze. Only endpoints can have
+			// openings leading outside of the maze, and nothing can open
+			// into a solid wall.
+			if (neighbor >= 0) && !m.cells[neighbor].state.outside() {
+				addViolation(ViolationOpenEdge, i, dir)
+			} else if !isEndpoint[i] {
+				addViolation(ViolationOpenEdge, i, dir)
+			}
+		}
+	}
+
+	// Check connectivity, and count passages and regions in the same way as
+	// Stats. Portals count as passages here, since they connect cells.
+	reached := make([]bool, len(m.cells))
+	stack := make([]int, 0, 64)
+	var neighbors []int
+	regions, cellCount, passages := 0, 0, 0
+	for i := range m.cells {
+		if m.cells[i].state.excluded() {
+			continue
+		}
+		cellCount++
+		passages += len(m.graphNeighbors(i, neighbors[:0]))
+	}
+	passages /= 2
+	flood := func(start int) {
+		reached[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) != 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			neighbors = m.graphNeighbors(current, neighbors[:0])
+			for _, n := range neighbors {
+				if !reached[n] {
+					reached[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+	}
+	if !m.cells[m.startCellIndex].state.excluded() {
+		regions++
+		flood(m.startCellIndex)
+	}
+	for i := range m.cells {
+		if reached[i] || m.cells[i].state.excluded() {
+			continue
+		}
+		addViolation(ViolationDisconnected, i, -1)
+	}
+	for i := range m.cells {
+		if reached[i] || m.cells[i].state.excluded() {
+			continue
+		}
+		regions++
+		flood(i)
+	}
+	// The disconnected cells were found after the wall checks, so put
+	// everything back in order of cell index.
+	sort.SliceStable(toReturn.Violations, func(a, b int) bool {
+		pa := toReturn.Violations[a].Cell
+		pb := toReturn.Violations[b].Cell
+		if pa.Y != pb.Y {
+			return pa.Y < pb.Y
+		}
+		return pa.X < pb.X
+	})
+	toReturn.Loops = passages - cellCount + regions
+	toReturn.Perfect = toReturn.Valid() && (toReturn.Loops == 0)
+	return toReturn
+}
@@ -0,0 +1,136 @@
+package maze
+
+import (
+	"image"
+	"testing"
+)
+
+// Returns true if the report contains a violation matching the given one.
+func hasViolation(r *ValidationReport, v MazeViolation) bool {
+	for _, other := range r.Violations {
+		if other == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Fails the test unless the report's violations are ordered by row, then by
+// column.
+func checkViolationOrder(t *testing.T, r *ValidationReport) {
+	for i := 1; i < len(r.Violations); i++ {
+		a := r.Violations[i-1].Cell
+		b := r.Violations[i].Cell
+		if (a.Y > b.Y) || ((a.Y == b.Y) && (a.X > b.X)) {
+			t.Fatalf("Violation %s is listed before %s", r.Violations[i-1],
+				r.Violations[i])
+		}
+	}
+}
+
+func TestValidateGeneratedMazes(t *testing.T) {
+	for seed := int64(1); seed <= 10; seed++ {
+		m := newTestMaze(t, 10, 10, seed, 0)
+		r := m.Validate()
+		if !r.Valid() || !r.Perfect {
+			t.Fatalf("Seed %d: generated maze isn't valid and perfect: %s",
+				seed, r)
+		}
+		e := m.ErodeWalls()
+		if e != nil {
+			t.Fatalf("Failed eroding walls: %s", e)
+		}
+		r = m.Validate()
+		if !r.Valid() || r.Perfect || (r.Loops <= 0) {
+			t.Fatalf("Seed %d: eroded maze isn't valid with loops: %s", seed,
+				r)
+		}
+	}
+}
+
+func TestValidateWithPortals(t *testing.T) {
+	for seed := int64(1); seed <= 10; seed++ {
+		m := newTestMaze(t, 10, 10, seed, 0)
+		added, e := m.PlacePortals(2, seed)
+		if e != nil {
+			t.Fatalf("Seed %d: failed placing portals: %s", seed, e)
+		}
+		r := m.Validate()
+		if !r.Valid() {
+			t.Fatalf("Seed %d: maze with %d portal(s) isn't valid: %s", seed,
+				added, r)
+		}
+	}
+}
+
+func TestValidateViolations(t *testing.T) {
+	m := newTestMaze(t, 6, 6, 1, 0)
+	// Open a wall on only one side of a pair of cells.
+	broken := image.Pt(2, 3)
+	index, _ := m.cellIndex(broken)
+	dir := -1
+	for d := 2; d <= 3; d++ {
+		if m.cells[index].walls[d] {
+			dir = d
+			break
+		}
+	}
+	if dir < 0 {
+		t.Fatalf("Cell %s has no walls to the right or below", broken)
+	}
+	m.cells[index].walls[dir] = false
+	// Open the outer wall of a cell on the left edge that isn't the start or
+	// the end.
+	edge := image.Pt(0, 1)
+	edgeIndex, _ := m.cellIndex(edge)
+	if (edgeIndex == m.startCellIndex) || (edgeIndex == m.endCellIndex) {
+		edge = image.Pt(0, 4)
+		edgeIndex, _ = m.cellIndex(edge)
+	}
+	m.cells[edgeIndex].walls[0] = false
+	r := m.Validate()
+	if r.Valid() || r.Perfect {
+		t.Fatalf("Broken maze was reported as valid: %s", r)
+	}
+	expected := MazeViolation{
+		Kind:      ViolationNonReciprocalWall,
+		Cell:      broken,
+		Direction: dir,
+	}
+	if !hasViolation(r, expected) {
+		t.Fatalf("Didn't find %s in %s", expected, r)
+	}
+	expected = MazeViolation{
+		Kind:      ViolationOpenEdge,
+		Cell:      edge,
+		Direction: 0,
+	}
+	if !hasViolation(r, expected) {
+		t.Fatalf("Didn't find %s in %s", expected, r)
+	}
+	checkViolationOrder(t, r)
+}
+
+func TestValidateDisconnected(t *testing.T) {
+	m := newTestMaze(t, 6, 6, 1, 0)
+	// Wall off a cell in the middle of the maze.
+	cell := image.Pt(3, 2)
+	index, _ := m.cellIndex(cell)
+	for dir := 0; dir < 4; dir++ {
+		m.setWall(index, dir, true)
+	}
+	// Disconnected cells are found after the wall checks, so also add an
+	// open edge in a later row to make sure the violations get sorted.
+	edgeIndex, _ := m.cellIndex(image.Pt(0, 5))
+	m.cells[edgeIndex].walls[0] = false
+	r := m.Validate()
+	expected := MazeViolation{
+		Kind:      ViolationDisconnected,
+		Cell:      cell,
+		Direction: -1,
+	}
+	if !hasViolation(r, expected) {
+		t.Fatalf("Didn't find %s in %s", expected, r)
+	}
+	checkViolationOrder(t, r)
+}